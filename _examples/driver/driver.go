@@ -1,19 +1,22 @@
 package main
 
 import (
-	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/chrislusf/gleam/distributed"
 	"github.com/chrislusf/gleam/flow"
 	"github.com/chrislusf/gleam/gio"
-	enry "gopkg.in/src-d/enry.v1"
+	"github.com/pkg/errors"
 
-	engine "github.com/eiso/go-engine"
+	"github.com/eiso/go-engine/queries"
+	"github.com/eiso/go-engine/readers"
+	"github.com/eiso/go-engine/sink"
+	"github.com/eiso/go-engine/sql"
 	"github.com/eiso/go-engine/utils"
 
 	"net/http"
@@ -24,10 +27,16 @@ func main() {
 
 	var (
 		query           = flag.String("query", "", "name the query you want to run")
+		sqlQuery        = flag.String("sql", "", "SQL query to run instead of a named one")
 		isDistributed   = flag.Bool("distributed", false, "run in distributed or not")
 		isDockerCluster = flag.Bool("onDocker", false, "run in docker cluster")
 		pathPtr         = flag.String("path", ".", "")
 		partitions      = flag.Int("partitions", 1, "number of partitions")
+		limit           = flag.Int("limit", 10, "maximum number of rows of the query, 0 for all")
+		refs            = flag.String("refs", "", "comma separated references the query reads, all by default")
+		parquetDir      = flag.String("parquet", "", "directory to write the rows to as Parquet files instead of printing them")
+		format          = flag.String("format", "tsv", "format of the rows printed: tsv, jsonl or csv")
+		keepComments    = flag.Bool("keepComments", false, "tell apart the files only differing in their comments")
 	)
 
 	go func() {
@@ -36,8 +45,8 @@ func main() {
 
 	gio.Init()
 
-	if *query == "" {
-		fmt.Print("please provide a query e.g. --query=test")
+	if *query == "" && *sqlQuery == "" {
+		fmt.Print("please provide a query e.g. --query=test or --sql='SELECT * FROM repositories'")
 		os.Exit(0)
 	}
 
@@ -50,13 +59,43 @@ func main() {
 
 	start := time.Now()
 
-	p, opts, err := queryExample(path, *query, *partitions)
+	var (
+		p      *flow.Dataset
+		schema readers.Schema
+		err    error
+	)
+	if *sqlQuery != "" {
+		p, schema, err = sqlExample(path, *sqlQuery, *partitions)
+	} else {
+		var refNames []string
+		if *refs != "" {
+			refNames = strings.Split(*refs, ",")
+		}
+		p, schema, err = queryExample(*query, queries.Params{
+			Path:         path,
+			Partitions:   *partitions,
+			Refs:         refNames,
+			Limit:        *limit,
+			KeepComments: *keepComments,
+		})
+	}
 	if err != nil {
 		fmt.Printf("could not load query: %s \n", err)
 		os.Exit(0)
 	}
 
-	p.OutputRow(utils.PrintRow)
+	if *parquetDir != "" {
+		sink.Parquet(p, *parquetDir, schema)
+	} else {
+		switch *format {
+		case "jsonl":
+			sink.JSONL(p, os.Stdout, schema)
+		case "csv":
+			sink.CSV(p, os.Stdout, schema)
+		default:
+			p.OutputRow(utils.PrintRow)
+		}
+	}
 
 	switch {
 	case *isDistributed:
@@ -73,79 +112,33 @@ var (
 	opts []flow.FlowOption
 )
 
-var getLanguagesFromBlobs = gio.RegisterMapper(utils.NamedMapper(
-	[]string{"lang"},
-	func(row []interface{}, getByName utils.GetByNameFunc) error {
-		isBinary := getByName(row, "isBinary").(bool)
-		if isBinary {
-			return nil
-		}
+func sqlExample(path, query string, partitions int) (*flow.Dataset, readers.Schema, error) {
+	// the query isn't part of the name, Gleam passes it through a shell
+	f := flow.New(fmt.Sprintf("Driver: sql on %s", path))
 
-		path := getByName(row, "path").(string)
-		content := gio.ToBytes(getByName(row, "content"))
-		lang := enry.GetLanguage(path, content)
-		if lang == "" {
-			return nil
-		}
+	d, err := sql.Compile(f, query, path, partitions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf(">>> %s", strings.Join(d.Columns(), "\t"))
+	return d.Dataset, d.Schema(), nil
+}
+
+func queryExample(query string, p queries.Params) (*flow.Dataset, readers.Schema, error) {
+	f := flow.New(fmt.Sprintf("Driver: %s on %s", query, p.Path))
 
-		return gio.Emit(lang)
-	}))
-
-var countGroups = gio.RegisterMapper(func(x []interface{}) error {
-	key := x[0]
-	count := len(x) - 1
-	return gio.Emit(key, count)
-})
-
-func queryExample(path, query string, partitions int) (*flow.Dataset, []flow.FlowOption, error) {
-	f := flow.New(fmt.Sprintf("Driver: %s on %s", query, path))
-	var p *flow.Dataset
-
-	switch query {
-	case "mostUsedLanguages":
-		numberOfLangs := 10
-		fmt.Printf(">>> %d most used languages:\n", numberOfLangs)
-    
-    		p = f.Read(engine.Repositories(path, partitions).
-			References().
-			Commits().
-			Trees().	
-      			Blobs().
-			WithHeaders()).
-			Map("classify languages", getLanguagesFromBlobs).
-			GroupBy("group by lang", flow.Field(1)).
-			Map("group count", countGroups).
-			Top("top", numberOfLangs, flow.OrderBy(2, false))
-		
-	case "repositories":
-		p = f.Read(engine.Repositories(path, partitions))
-	case "references":
-		p = f.Read(engine.Repositories(path, partitions).
-			References())
-	case "referencesMaster":
-		p = f.Read(engine.Repositories(path, partitions).
-			References().Filter("refs/heads/master"))
-	case "commits":
-		p = f.Read(engine.Repositories(path, partitions).
-			References().
-			Commits())
-	case "commitsMaster":
-		p = f.Read(engine.Repositories(path, partitions).
-			References().Filter("refs/heads/master").
-			Commits())
-	case "trees":
-		p = f.Read(engine.Repositories(path, partitions).
-			References().
-			Commits().
-			Trees())
-	case "blobs":
-		p = f.Read(engine.Repositories(path, partitions).
-			References().
-			Commits().
-			Trees().
-			Blobs())
-	default:
-		return nil, nil, errors.New("this query is not implemented")
+	q, err := queries.Get(query)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "available queries are %s", strings.Join(queries.Names(), ", "))
 	}
-	return p, opts, nil
+
+	log.Printf(">>> %s:", query)
+	d, err := q(f, p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf(">>> %s", strings.Join(d.Columns(), "\t"))
+	return d.Dataset, d.Schema(), nil
 }
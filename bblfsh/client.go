@@ -0,0 +1,85 @@
+package bblfsh
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+// MaxMessageSize is the size of the largest response the client receives,
+// instead of the 4MB by default of gRPC, as the UASTs of large files are
+// much larger than their content.
+const MaxMessageSize = 64 << 20
+
+// Client sends requests to a Babelfish server over a pool of connections,
+// which are opened when first used.
+type Client struct {
+	endpoint string
+
+	mu    sync.Mutex
+	conns []*grpc.ClientConn
+	next  uint32
+}
+
+// NewClient returns a client of the server at endpoint, like
+// localhost:9432, using up to size connections.
+func NewClient(endpoint string, size int) *Client {
+	if size < 1 {
+		size = 1
+	}
+	return &Client{endpoint: endpoint, conns: make([]*grpc.ClientConn, size)}
+}
+
+// conn returns the next connection of the pool, round robin.
+func (c *Client) conn() (*grpc.ClientConn, error) {
+	i := int(atomic.AddUint32(&c.next, 1)) % len(c.conns)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conns[i] == nil {
+		conn, err := grpc.Dial(c.endpoint,
+			grpc.WithInsecure(),
+			grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(MaxMessageSize)),
+		)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not connect to babelfish at %s", c.endpoint)
+		}
+		c.conns[i] = conn
+	}
+	return c.conns[i], nil
+}
+
+// Parse parses the content of a file.
+func (c *Client) Parse(ctx context.Context, req *ParseRequest) (*ParseResponse, error) {
+	conn, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(ParseResponse)
+	if err := conn.Invoke(ctx, "/"+service+"/Parse", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Close closes the connections of the pool.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+	for i, conn := range c.conns {
+		if conn == nil {
+			continue
+		}
+		if cerr := conn.Close(); err == nil {
+			err = cerr
+		}
+		c.conns[i] = nil
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package bblfsh
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeServer answers every request with a UAST of size bytes.
+type fakeServer struct {
+	size int
+}
+
+func (s *fakeServer) Parse(ctx context.Context, req *ParseRequest) (*ParseResponse, error) {
+	return &ParseResponse{
+		Status:   Ok,
+		UAST:     bytes.Repeat([]byte{'u'}, s.size),
+		Language: req.Language,
+	}, nil
+}
+
+// newTestClient serves srv and returns a client of it.
+func newTestClient(t *testing.T, srv Server) *Client {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	RegisterServer(s, srv)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+
+	c := NewClient(l.Addr().String(), 1)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestParseLargeUAST(t *testing.T) {
+	// larger than the 4MB gRPC receives by default
+	size := 5 << 20
+	c := newTestClient(t, &fakeServer{size: size})
+
+	resp, err := c.Parse(context.Background(), &ParseRequest{Language: "go", Content: "package a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.UAST) != size || resp.Language != "go" {
+		t.Errorf("expected a go UAST of %d bytes, got a %s UAST of %d", size, resp.Language, len(resp.UAST))
+	}
+}
+
+func TestParseTooLargeUAST(t *testing.T) {
+	c := newTestClient(t, &fakeServer{size: MaxMessageSize})
+
+	_, err := c.Parse(context.Background(), &ParseRequest{Language: "go", Content: "package a"})
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Errorf("expected %v, got %v", codes.ResourceExhausted, err)
+	}
+}
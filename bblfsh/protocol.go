@@ -0,0 +1,96 @@
+// Package bblfsh is a minimal client of the Babelfish protocol, which parses
+// source code into universal abstract syntax trees (UASTs), see
+// https://doc.bblf.sh. It only implements the Parse method, and keeps the
+// UASTs serialized as the server sends them.
+package bblfsh
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+)
+
+// service is the gRPC service of the version 1 of the protocol.
+const service = "gopkg.in.bblfsh.sdk.v1.protocol.ProtocolService"
+
+// Status is the result of a request.
+type Status int32
+
+// Statuses of the responses.
+const (
+	// Ok means the content was parsed.
+	Ok Status = iota
+	// Error means the content was parsed with errors, and the UAST may be
+	// incomplete.
+	Error
+	// Fatal means the content could not be parsed.
+	Fatal
+)
+
+// ParseRequest asks to parse the content of a file. The language is guessed
+// by the server if it is empty.
+type ParseRequest struct {
+	Filename string `protobuf:"bytes,1,opt,name=filename,proto3"`
+	Language string `protobuf:"bytes,2,opt,name=language,proto3"`
+	Content  string `protobuf:"bytes,3,opt,name=content,proto3"`
+}
+
+func (m *ParseRequest) Reset()         { *m = ParseRequest{} }
+func (m *ParseRequest) String() string { return proto.CompactTextString(m) }
+func (*ParseRequest) ProtoMessage()    {}
+
+// ParseResponse holds the UAST of the content parsed, serialized as the
+// gopkg.in.bblfsh.sdk.v1.uast.Node message.
+type ParseResponse struct {
+	Status   Status   `protobuf:"varint,1,opt,name=status,proto3"`
+	Errors   []string `protobuf:"bytes,2,rep,name=errors"`
+	UAST     []byte   `protobuf:"bytes,4,opt,name=uast,proto3"`
+	Language string   `protobuf:"bytes,5,opt,name=language,proto3"`
+}
+
+func (m *ParseResponse) Reset()         { *m = ParseResponse{} }
+func (m *ParseResponse) String() string { return proto.CompactTextString(m) }
+func (*ParseResponse) ProtoMessage()    {}
+
+// Server is the server side of the protocol, implemented by fake servers
+// to test the clients without Babelfish.
+type Server interface {
+	Parse(context.Context, *ParseRequest) (*ParseResponse, error)
+}
+
+// RegisterServer serves the protocol with srv.
+func RegisterServer(s *grpc.Server, srv Server) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: service,
+	HandlerType: (*Server)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Parse",
+			Handler:    parseHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func parseHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(ParseRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Server).Parse(ctx, req)
+	}
+
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/" + service + "/Parse",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Server).Parse(ctx, req.(*ParseRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
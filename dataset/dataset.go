@@ -0,0 +1,340 @@
+// Package dataset wraps Gleam datasets to refer to their columns by name.
+//
+// Gleam rows are plain lists of values, so the names of the columns are
+// tracked while the flow is built instead of being sent as a header row,
+// which would only reach one of the partitions. Every step that needs the
+// columns in a given order, like a mapper or a join, is preceded by a
+// projection that puts them in that order.
+package dataset
+
+import (
+	"log"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/eiso/go-engine/readers"
+	"github.com/pkg/errors"
+)
+
+// Source is a Gleam source that knows the columns of the rows it reads, like
+// the ones built by engine.Repositories.
+type Source interface {
+	flow.Sourcer
+	Schema() (readers.Schema, error)
+}
+
+// Dataset is a Gleam dataset with named columns. Methods of the embedded
+// dataset can still be used, but the datasets they return are unnamed.
+type Dataset struct {
+	*flow.Dataset
+	columns []string
+	// group are the columns of the rows grouped by GroupBy, which follow the
+	// key columns as a variable number of values.
+	group []string
+	alias string
+	// types are the columns read from a source, by name, see Schema.
+	types map[string]readers.Column
+}
+
+// Read reads the source into a dataset named after the source schema. The
+// source must not be read WithHeaders.
+func Read(f *flow.Flow, source Source) (*Dataset, error) {
+	schema, err := source.Schema()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get the schema of the source")
+	}
+	d := New(f.Read(source), schema.Names()...)
+	d.types = make(map[string]readers.Column, len(schema))
+	for _, c := range schema {
+		d.types[c.Name] = c
+	}
+	return d, nil
+}
+
+// New names the columns of a Gleam dataset. The columns have no type.
+func New(d *flow.Dataset, columns ...string) *Dataset {
+	return &Dataset{Dataset: d, columns: columns}
+}
+
+// Derive names the columns of a Gleam dataset computed from the rows of d,
+// like the result of one of its methods. The columns with the name of a
+// column of d keep its type, so ret must emit their values unchanged.
+func (d *Dataset) Derive(ret *flow.Dataset, columns ...string) *Dataset {
+	return d.next(ret, columns)
+}
+
+// Rename names the columns of the dataset anew, in the same order. The
+// columns keep their types.
+func (d *Dataset) Rename(columns ...string) *Dataset {
+	if len(columns) != len(d.columns) {
+		log.Panicf("can't rename the columns %v to %v", d.columns, columns)
+	}
+
+	ret := *d
+	ret.columns = columns
+	ret.types = make(map[string]readers.Column, len(columns))
+	for i, c := range d.columns {
+		if t, ok := d.types[c]; ok {
+			t.Name = columns[i]
+			ret.types[t.Name] = t
+		}
+	}
+	return &ret
+}
+
+// Columns returns the names of the columns. For grouped datasets they are
+// the key columns.
+func (d *Dataset) Columns() []string {
+	return d.columns
+}
+
+// Schema returns the columns with the types of the source they were read
+// from, see Read. The columns emitted by mappers have no type and are
+// nullable, like the ones of sink.Untyped.
+func (d *Dataset) Schema() readers.Schema {
+	schema := make(readers.Schema, len(d.columns))
+	for i, c := range d.columns {
+		t, ok := d.types[c]
+		if !ok {
+			t = readers.Column{Name: c, Nullable: true}
+		}
+		schema[i] = t
+	}
+	return schema
+}
+
+// GroupColumns returns the columns of the grouped rows, if any.
+func (d *Dataset) GroupColumns() []string {
+	return d.group
+}
+
+// As names the dataset, so that its columns whose names are already taken
+// are prefixed with alias when joined to another dataset. The default
+// alias is "right".
+func (d *Dataset) As(alias string) *Dataset {
+	ret := *d
+	ret.alias = alias
+	return &ret
+}
+
+func (d *Dataset) index(column string) int {
+	for i, c := range d.columns {
+		if c == column {
+			return i
+		}
+	}
+	log.Panicf("column '%s' not found in %v", column, d.columns)
+	return -1
+}
+
+func (d *Dataset) fields(columns []string) *flow.SortOption {
+	indexes := make([]int, len(columns))
+	for i, c := range columns {
+		indexes[i] = d.index(c) + 1
+	}
+	return flow.Field(indexes...)
+}
+
+func (d *Dataset) next(ret *flow.Dataset, columns []string) *Dataset {
+	return &Dataset{Dataset: ret, columns: columns, alias: d.alias, types: d.types}
+}
+
+func (d *Dataset) mustNotBeGrouped(step string) {
+	if d.group != nil {
+		log.Panicf("%s can only be used on datasets that are not grouped", step)
+	}
+}
+
+// Select keeps the given columns in the given order.
+func (d *Dataset) Select(name string, columns ...string) *Dataset {
+	d.mustNotBeGrouped("Select")
+	if equal(d.columns, columns) {
+		return d
+	}
+	return d.next(d.Dataset.Select(name, d.fields(columns)), columns)
+}
+
+// Map runs the mapper on the columns it was registered with. The resulting
+// dataset has the columns the mapper emits, which have no type. Mappers of
+// grouped datasets must be registered with the key columns.
+func (d *Dataset) Map(name string, m Mapper) *Dataset {
+	if d.group != nil {
+		if !equal(d.columns, m.In) {
+			log.Panicf("mapper %s of grouped rows should read %v instead of %v", name, d.columns, m.In)
+		}
+		return New(d.Dataset.Map(name, m.ID), m.Out...).As(d.alias)
+	}
+
+	in := d.Select(name+".select", m.In...)
+	return New(in.Dataset.Map(name, m.ID), m.Out...).As(d.alias)
+}
+
+// GroupBy groups the rows by the given columns. The rest of the columns of
+// every row of a group follow the keys, see Row.Group.
+//
+// Unlike flow.Dataset.GroupBy, rows are partitioned by their keys and
+// grouped in every partition, instead of merging the groups of all the
+// partitions into one, which nests the groups found in different
+// partitions.
+func (d *Dataset) GroupBy(name string, keys ...string) *Dataset {
+	rest := d.without(keys)
+	in := d.Select(name+".select", append(append([]string(nil), keys...), rest...)...)
+
+	option := flow.Field(keyIndexes(len(keys))...)
+	grouped := in.Dataset.
+		Partition(name, len(in.Dataset.Shards), option).
+		LocalSort(name, option).
+		LocalGroupBy(name, option)
+
+	ret := d.next(grouped, keys)
+	ret.group = rest
+	return ret
+}
+
+// Join joins the rows of both datasets with the same values in the given
+// columns. The result has the join columns followed by the rest of the
+// columns of d and then the rest of the columns of other.
+func (d *Dataset) Join(name string, other *Dataset, on ...string) *Dataset {
+	d.mustNotBeGrouped("Join")
+	other.mustNotBeGrouped("Join")
+
+	left := d.without(on)
+	right := other.without(on)
+	l := d.Select(name+".left", append(append([]string(nil), on...), left...)...)
+	r := other.Select(name+".right", append(append([]string(nil), on...), right...)...)
+
+	columns := append(append([]string(nil), on...), left...)
+	types := make(map[string]readers.Column)
+	for _, c := range columns {
+		if t, ok := d.types[c]; ok {
+			types[c] = t
+		}
+	}
+
+	alias := other.alias
+	if alias == "" {
+		alias = "right"
+	}
+	for _, c := range right {
+		t, ok := other.types[c]
+		if contains(columns, c) {
+			c = alias + "." + c
+		}
+		columns = append(columns, c)
+		if ok {
+			t.Name = c
+			types[c] = t
+		}
+	}
+
+	joined := l.Dataset.Join(name, r.Dataset, flow.Field(keyIndexes(len(on))...))
+	return &Dataset{Dataset: joined, columns: columns, alias: d.alias, types: types}
+}
+
+// Order is the column to sort by and the direction.
+type Order struct {
+	Column    string
+	Ascending bool
+}
+
+// Asc sorts by column in ascending order.
+func Asc(column string) Order { return Order{Column: column, Ascending: true} }
+
+// Desc sorts by column in descending order.
+func Desc(column string) Order { return Order{Column: column} }
+
+// orderBy moves the columns to order by to the front, since Gleam does so
+// when merging sorted partitions, and returns the option to sort by them.
+// The rows of grouped datasets can only be ordered by their first keys.
+func (d *Dataset) orderBy(name string, orders []Order) (*Dataset, *flow.SortOption) {
+	if len(orders) == 0 {
+		log.Panicf("no columns to order by")
+	}
+
+	columns := make([]string, len(orders))
+	for i, o := range orders {
+		columns[i] = o.Column
+	}
+
+	in := d
+	if d.group != nil {
+		if len(columns) > len(d.columns) || !equal(d.columns[:len(columns)], columns) {
+			log.Panicf("grouped rows can only be ordered by their first keys %v", d.columns)
+		}
+	} else {
+		in = d.Select(name+".select", append(columns, d.without(columns)...)...)
+	}
+
+	option := flow.OrderBy(1, orders[0].Ascending)
+	for i, o := range orders[1:] {
+		option = option.By(i+2, o.Ascending)
+	}
+	return in, option
+}
+
+// Sort sorts the rows by the given columns, which become the first ones.
+func (d *Dataset) Sort(name string, orders ...Order) *Dataset {
+	in, option := d.orderBy(name, orders)
+	ret := in.next(in.Dataset.Sort(name, option), in.columns)
+	ret.group = in.group
+	return ret
+}
+
+// Top keeps the first k rows sorted by the given columns, which become the
+// first ones.
+func (d *Dataset) Top(name string, k int, orders ...Order) *Dataset {
+	in, option := d.orderBy(name, orders)
+
+	// flow.Dataset.Top marks the rows of every partition as sorted in the
+	// reverse order, and merges them wrong, so they are sorted again
+	top := in.Dataset.LocalTop(name, k, option)
+	if len(top.Shards) > 1 {
+		top = top.LocalSort(name, option).MergeSortedTo(name, 1).LocalLimit(name, k, 0)
+	}
+
+	ret := in.next(top, in.columns)
+	ret.group = in.group
+	return ret
+}
+
+func (d *Dataset) without(columns []string) []string {
+	for _, c := range columns {
+		d.index(c)
+	}
+
+	var rest []string
+	for _, c := range d.columns {
+		if !contains(columns, c) {
+			rest = append(rest, c)
+		}
+	}
+	return rest
+}
+
+func keyIndexes(n int) []int {
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i + 1
+	}
+	return indexes
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package dataset
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	"github.com/eiso/go-engine/readers"
+)
+
+// source reads no rows with the columns of the schema.
+type source readers.Schema
+
+func (s source) Generate(f *flow.Flow) *flow.Dataset {
+	return f.Strings(nil)
+}
+
+func (s source) Schema() (readers.Schema, error) {
+	return readers.Schema(s), nil
+}
+
+var countPaths = RegisterMapper([]string{"path"}, []string{"path", "count"}, func(row Row) error {
+	return gio.Emit(row.Get("path"), int64(len(row.Group())))
+})
+
+func TestSchema(t *testing.T) {
+	f := flow.New("test")
+	blobs, err := Read(f, source(readers.BlobsSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits, err := Read(f, source(readers.CommitsSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		id      = readers.Column{Name: "repositoryID", Type: readers.String}
+		content = readers.Column{Name: "content", Type: readers.Bytes}
+		size    = readers.Column{Name: "blobSize", Type: readers.Int64}
+		untyped = func(name string) readers.Column { return readers.Column{Name: name, Nullable: true} }
+	)
+
+	joined := blobs.
+		Select("blobs", "repositoryID", "commitHash", "content").
+		Join("join", commits.Select("commits", "commitHash", "repositoryID", "parentsCount").As("c"), "commitHash")
+
+	cases := []struct {
+		name     string
+		d        *Dataset
+		expected readers.Schema
+	}{
+		{"Select", blobs.Select("select", "content", "repositoryID"), readers.Schema{content, id}},
+		{
+			"Join", joined,
+			readers.Schema{
+				{Name: "commitHash", Type: readers.String},
+				id,
+				content,
+				{Name: "c.repositoryID", Type: readers.String},
+				{Name: "parentsCount", Type: readers.Int64},
+			},
+		},
+		{"GroupBy", blobs.GroupBy("group", "blobSize"), readers.Schema{size}},
+		{"Sort", blobs.Select("select", "path", "blobSize").Sort("sort", Desc("blobSize")), readers.Schema{size, {Name: "path", Type: readers.String}}},
+		{"Map", blobs.GroupBy("group", "path").Map("count", countPaths), readers.Schema{untyped("path"), untyped("count")}},
+		{"New", New(f.Strings(nil), "content"), readers.Schema{untyped("content")}},
+		{
+			"Rename", blobs.Select("select", "repositoryID", "content").Rename("r", "c"),
+			readers.Schema{{Name: "r", Type: readers.String}, {Name: "c", Type: readers.Bytes}},
+		},
+	}
+
+	for _, c := range cases {
+		if got := c.d.Schema(); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("expected the schema %v after %s, got %v", c.expected, c.name, got)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package dataset
+
+import (
+	"log"
+
+	"github.com/chrislusf/gleam/gio"
+)
+
+// Mapper is a Gleam mapper that knows the columns it reads and emits.
+type Mapper struct {
+	ID gio.MapperId
+	// In are the columns the mapper reads, in the order it receives them.
+	In []string
+	// Out are the columns of the rows the mapper emits.
+	Out []string
+}
+
+// MapperFunc processes a row, emitting any number of rows with gio.Emit.
+type MapperFunc func(row Row) error
+
+// RegisterMapper registers a mapper reading the columns in and emitting the
+// columns out. Like gio.RegisterMapper, it must be called when the program
+// starts, usually to initialize a package variable.
+func RegisterMapper(in, out []string, fn MapperFunc) Mapper {
+	names := make(map[string]int, len(in))
+	for i, c := range in {
+		names[c] = i
+	}
+
+	id := gio.RegisterMapper(func(x []interface{}) error {
+		// Gleam groups empty partitions into a single empty row
+		if len(x) == 0 {
+			return nil
+		}
+		return fn(Row{values: x, names: names})
+	})
+	return Mapper{ID: id, In: in, Out: out}
+}
+
+// Row gives access to the values of a row by column name.
+type Row struct {
+	values []interface{}
+	names  map[string]int
+}
+
+// Values returns all the values of the row.
+func (r Row) Values() []interface{} {
+	return r.values
+}
+
+// Get returns the value of the column. It panics if the mapper does not read
+// such column.
+func (r Row) Get(column string) interface{} {
+	i, ok := r.names[column]
+	if !ok {
+		log.Panicf("column '%s' not found", column)
+	}
+	return r.values[i]
+}
+
+// String returns the value of the column as a string.
+func (r Row) String(column string) string {
+	return gio.ToString(r.Get(column))
+}
+
+// Bytes returns the value of the column as bytes.
+func (r Row) Bytes(column string) []byte {
+	return gio.ToBytes(r.Get(column))
+}
+
+// Int64 returns the value of the column as an int64.
+func (r Row) Int64(column string) int64 {
+	return gio.ToInt64(r.Get(column))
+}
+
+// Bool returns the value of the column, which is false unless it is a true
+// bool.
+func (r Row) Bool(column string) bool {
+	b, _ := r.Get(column).(bool)
+	return b
+}
+
+// Group returns the rows grouped under the keys of the row by GroupBy, each
+// of them holding the values of the dataset group columns.
+func (r Row) Group() [][]interface{} {
+	rest := r.values[len(r.names):]
+	group := make([][]interface{}, len(rest))
+	for i, v := range rest {
+		// rows without group columns are decoded as nil
+		group[i], _ = v.([]interface{})
+	}
+	return group
+}
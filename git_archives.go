@@ -0,0 +1,322 @@
+package git
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/eiso/go-engine/gleamfs"
+	"github.com/pkg/errors"
+
+	billy "gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	gitfs "gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+// Repositories stored in archives.
+const (
+	// repoTypeBundle is a file created with git bundle.
+	repoTypeBundle = "bundle"
+	// repoTypeTarball is a tar file, optionally gzipped, of a repository.
+	repoTypeTarball = "tarball"
+)
+
+const (
+	bundleV2Signature = "# v2 git bundle"
+	bundleV3Signature = "# v3 git bundle"
+)
+
+var tarballExtensions = []string{".tar", ".tar.gz", ".tgz"}
+
+func (s *baseSource) isBundleFile(path string) bool {
+	if filepath.Ext(path) != ".bundle" {
+		return false
+	}
+	f, err := gleamfs.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	signature, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	signature = strings.TrimSpace(signature)
+	return signature == bundleV2Signature || signature == bundleV3Signature
+}
+
+func (s *baseSource) isTarball(path string) bool {
+	if !hasTarballExtension(path) {
+		return false
+	}
+	f, err := gleamfs.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return f.Size() > 0
+}
+
+func hasTarballExtension(path string) bool {
+	for _, ext := range tarballExtensions {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// readBundle writes the packfile of a bundle to a temporary directory and
+// creates the references listed in its header. HEAD points to the master
+// branch when the bundle does not include it. The directory is removed when
+// the repository is closed. Like tarballs, bundles are not loaded in memory
+// since they can be as big as the biggest repositories.
+func readBundle(origPath string) (*git.Repository, io.Closer, error) {
+	dir, err := ioutil.TempDir("", "go-engine-bundle")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to create a temporary directory")
+	}
+	remove := closerFunc(func() error { return os.RemoveAll(dir) })
+
+	repository, err := openBundle(origPath, osfs.New(dir))
+	if err != nil {
+		remove.Close()
+		return nil, nil, err
+	}
+	return repository, remove, nil
+}
+
+func openBundle(origPath string, fs billy.Filesystem) (*git.Repository, error) {
+	f, err := gleamfs.Open(origPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open the bundle")
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	refs, err := readBundleHeader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the bundle header")
+	}
+
+	sto, err := gitfs.NewStorage(fs)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create a new storage backend")
+	}
+
+	if err := packfile.UpdateObjectStorage(sto, r); err != nil {
+		return nil, errors.Wrap(err, "unable to read the bundle packfile")
+	}
+
+	hasHead := false
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD {
+			hasHead = true
+		}
+		if err := sto.SetReference(ref); err != nil {
+			return nil, errors.Wrapf(err, "unable to set reference %s", ref.Name())
+		}
+	}
+	if !hasHead {
+		head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)
+		if err := sto.SetReference(head); err != nil {
+			return nil, errors.Wrap(err, "unable to set HEAD")
+		}
+	}
+
+	// the worktree is never read, but go-git requires one for repositories
+	// not configured as bare.
+	repository, err := git.Open(sto, memfs.New())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open the git repository")
+	}
+	return repository, nil
+}
+
+// readBundleHeader reads the header of a bundle up to the empty line before
+// the packfile. Prerequisites are logged since the commits depending on them
+// can't be fully read.
+func readBundleHeader(r *bufio.Reader) ([]*plumbing.Reference, error) {
+	var refs []*plumbing.Reference
+	for i := 0; ; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case i == 0:
+			if line != bundleV2Signature && line != bundleV3Signature {
+				return nil, errors.Errorf("unknown bundle signature %q", line)
+			}
+		case line == "":
+			return refs, nil
+		case strings.HasPrefix(line, "@"):
+			// v3 capabilities, none of them changes how the pack is read
+		case strings.HasPrefix(line, "-"):
+			log.Printf("bundle requires commit %s which is not included", strings.Fields(line[1:])[0])
+		default:
+			fields := strings.SplitN(line, " ", 2)
+			if len(fields) != 2 {
+				return nil, errors.Errorf("invalid reference line %q", line)
+			}
+			name := plumbing.ReferenceName(fields[1])
+			refs = append(refs, plumbing.NewHashReference(name, plumbing.NewHash(fields[0])))
+		}
+	}
+}
+
+// readTarball extracts the tarball to a temporary directory and opens the
+// repository it contains, either at its root or in one of its top level
+// directories. The directory is removed when the repository is closed.
+// Tarballs are not extracted in memory since they can be as big as the
+// biggest repositories.
+func readTarball(origPath string) (*git.Repository, io.Closer, error) {
+	dir, err := ioutil.TempDir("", "go-engine-tarball")
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to create a temporary directory")
+	}
+	remove := closerFunc(func() error { return os.RemoveAll(dir) })
+
+	repository, err := openTarball(origPath, osfs.New(dir))
+	if err != nil {
+		remove.Close()
+		return nil, nil, err
+	}
+	return repository, remove, nil
+}
+
+func openTarball(origPath string, fs billy.Filesystem) (*git.Repository, error) {
+	f, err := gleamfs.Open(origPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open the tarball")
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if !strings.HasSuffix(origPath, ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to decompress the tarball")
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	if err := extractTar(tar.NewReader(r), fs); err != nil {
+		return nil, errors.Wrap(err, "unable to extract the tarball")
+	}
+
+	dotGit, err := findGitDir(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	sto, err := gitfs.NewStorage(dotGit)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create a new storage backend")
+	}
+
+	// the worktree is never read, but go-git requires one for repositories
+	// not configured as bare.
+	repository, err := git.Open(sto, memfs.New())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open the git repository")
+	}
+	return repository, nil
+}
+
+func extractTar(tr *tar.Reader, fs billy.Filesystem) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name, ok := extractedName(hdr.Name)
+		if !ok {
+			return errors.Errorf("%s is outside of the tarball", hdr.Name)
+		}
+		if name == "." {
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := fs.MkdirAll(name, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			file, err := fs.Create(name)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(file, tr)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractedName returns the path where a tar entry is extracted, relative
+// to the directory the tarball is extracted to, or false if it is outside
+// of it, like ../config or a/../../config.
+func extractedName(name string) (string, bool) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
+
+// findGitDir looks for a git directory at the root of fs, in a .git
+// directory or in any top level directory.
+func findGitDir(fs billy.Filesystem) (billy.Filesystem, error) {
+	candidates := []string{"", ".git"}
+	entries, err := fs.ReadDir("")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list the tarball")
+	}
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != ".git" {
+			candidates = append(candidates, e.Name(), path.Join(e.Name(), ".git"))
+		}
+	}
+
+	for _, dir := range candidates {
+		if isBillyGitDir(fs, dir) {
+			return fs.Chroot(dir)
+		}
+	}
+	return nil, errors.New("no git repository found in the tarball")
+}
+
+func isBillyGitDir(fs billy.Filesystem, dir string) bool {
+	for _, name := range []string{"objects", "refs"} {
+		fi, err := fs.Stat(path.Join(dir, name))
+		if err != nil || !fi.IsDir() {
+			return false
+		}
+	}
+	_, err := fs.Stat(path.Join(dir, "HEAD"))
+	return err == nil
+}
@@ -0,0 +1,85 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestExtractedName(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+		ok       bool
+	}{
+		{"repo/.git/HEAD", "repo/.git/HEAD", true},
+		{"/repo/HEAD", "repo/HEAD", true},
+		{"./repo/./objects/", "repo/objects", true},
+		{"repo/../HEAD", "HEAD", true},
+		{".", ".", true},
+		{"..", "", false},
+		{"../HEAD", "", false},
+		{"repo/../../HEAD", "", false},
+		{"/../HEAD", "", false},
+	}
+
+	for _, c := range cases {
+		name, ok := extractedName(c.name)
+		if name != c.expected || ok != c.ok {
+			t.Errorf("extractedName(%q) = %q, %v, expected %q, %v", c.name, name, ok, c.expected, c.ok)
+		}
+	}
+}
+
+func TestReadBundle(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is needed to create the bundle")
+	}
+
+	dir, err := ioutil.TempDir("", "bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	repo := filepath.Join(dir, "repo")
+	bundle := filepath.Join(dir, "repo.bundle")
+	for _, args := range [][]string{
+		{"init", "-q", repo},
+		{"-C", repo, "-c", "user.name=a", "-c", "user.email=a@example.com", "commit", "-q", "--allow-empty", "-m", "first"},
+		{"-C", repo, "bundle", "create", bundle, "--all"},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	r, closer, err := readBundle(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := r.Reference(plumbing.HEAD, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CommitObject(head.Hash()); err != nil {
+		t.Errorf("expected HEAD to point to a commit: %v", err)
+	}
+
+	packs, _ := filepath.Glob(filepath.Join(os.TempDir(), "go-engine-bundle*", "objects", "pack", "*.pack"))
+	if len(packs) == 0 {
+		t.Error("expected the packfile to be written to a temporary directory")
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, pack := range packs {
+		if _, err := os.Stat(pack); err == nil {
+			t.Errorf("expected %s to be removed on close", pack)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package git
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/eiso/go-engine/gleamfs"
+	"github.com/pkg/errors"
+
+	billy "gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	git "gopkg.in/src-d/go-git.v4"
+	gitfs "gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+// Layouts a repository found by the source walker can have.
+const (
+	// repoTypeStandard is a working copy with a .git directory.
+	repoTypeStandard = "standard"
+	// repoTypeBare is a repository with HEAD, objects and refs at its root.
+	repoTypeBare = "bare"
+	// repoTypeGitDir is a working copy whose .git is a file pointing to
+	// the real git directory, as left by submodules or --separate-git-dir.
+	repoTypeGitDir = "gitdir"
+	// repoTypeWorktree is a linked worktree created with git worktree add,
+	// which shares its objects and references with the main repository.
+	repoTypeWorktree = "worktree"
+	// repoTypeSiva is a repository packed in a siva file.
+	repoTypeSiva = "siva"
+)
+
+const gitDirPrefix = "gitdir:"
+
+// repositoryType returns the layout of the repository at path, or an empty
+// string if path is not a repository.
+func (s *baseSource) repositoryType(path string) string {
+	if !gleamfs.IsDir(path) {
+		switch {
+		case s.isSivaFile(path):
+			return repoTypeSiva
+		case s.isBundleFile(path):
+			return repoTypeBundle
+		case s.isTarball(path):
+			return repoTypeTarball
+		}
+		return ""
+	}
+
+	if gleamfs.IsDir(gleamfs.Join(path, ".git")) {
+		return repoTypeStandard
+	}
+
+	if gitDir, err := readGitDirFile(path); err == nil {
+		if isLinkedWorktree(gitDir) {
+			return repoTypeWorktree
+		}
+		return repoTypeGitDir
+	}
+
+	if isBareRepository(path) {
+		return repoTypeBare
+	}
+
+	return ""
+}
+
+func (s *baseSource) isSivaFile(path string) bool {
+	ext := filepath.Ext(path)
+	if ext != ".siva" {
+		return false
+	}
+	ps, err := gleamfs.Open(path)
+	if err != nil {
+		return false
+	}
+	defer ps.Close()
+	if ps.Size() == 0 {
+		return false
+	}
+
+	// open the siva file to see if it is a valid git repository
+	// if _, err := readSiva(path); err != nil {
+	// 	return false
+	// }
+
+	return true
+}
+
+// isBareRepository checks for the files git itself requires to consider a
+// directory a git directory. The refs directory is not required in remote
+// locations since object stores like S3 have no empty directories.
+func isBareRepository(path string) bool {
+	if !gleamfs.IsDir(gleamfs.Join(path, "objects")) {
+		return false
+	}
+	if !gleamfs.IsRemote(path) && !gleamfs.IsDir(gleamfs.Join(path, "refs")) {
+		return false
+	}
+
+	head, err := gleamfs.Open(gleamfs.Join(path, "HEAD"))
+	if err != nil {
+		return false
+	}
+	head.Close()
+	return true
+}
+
+// isLinkedWorktree reports whether gitDir belongs to a linked worktree, that
+// is, it has a commondir file pointing to the main git directory.
+func isLinkedWorktree(gitDir string) bool {
+	_, err := readPathFile(gleamfs.Join(gitDir, "commondir"), "", gitDir)
+	return err == nil
+}
+
+// readGitDirFile resolves the git directory referenced by the .git file of
+// the working copy at path.
+func readGitDirFile(path string) (string, error) {
+	return readPathFile(gleamfs.Join(path, ".git"), gitDirPrefix, path)
+}
+
+// readPathFile reads a file holding a single path, like .git files or
+// commondir, and resolves it relative to base when it is not absolute.
+func readPathFile(file, prefix, base string) (string, error) {
+	f, err := gleamfs.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if gleamfs.IsDir(file) {
+		return "", errors.Errorf("%s is a directory", file)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not read %s", file)
+	}
+
+	line := strings.TrimSpace(strings.SplitN(string(b), "\n", 2)[0])
+	if !strings.HasPrefix(line, prefix) {
+		return "", errors.Errorf("%s does not start with %q", file, prefix)
+	}
+
+	path := strings.TrimSpace(line[len(prefix):])
+	if path == "" {
+		return "", errors.Errorf("%s is empty", file)
+	}
+	if !filepath.IsAbs(path) {
+		path = gleamfs.Join(base, path)
+	}
+	return path, nil
+}
+
+// gitDirectory returns the directory holding the objects and references of
+// the repository at path. Archived repositories have no such directory.
+func gitDirectory(path, repoType string) (string, error) {
+	switch repoType {
+	case repoTypeStandard:
+		return gleamfs.Join(path, ".git"), nil
+	case repoTypeBare:
+		return path, nil
+	case repoTypeGitDir:
+		return readGitDirFile(path)
+	case repoTypeWorktree:
+		gitDir, err := readGitDirFile(path)
+		if err != nil {
+			return "", errors.Wrap(err, "could not resolve git directory")
+		}
+		commonDir, err := readPathFile(gleamfs.Join(gitDir, "commondir"), "", gitDir)
+		if err != nil {
+			return "", errors.Wrap(err, "could not resolve common directory")
+		}
+		return commonDir, nil
+	}
+	return "", errors.Errorf("%s repositories have no git directory", repoType)
+}
+
+// OpenRepository opens the repository at path whatever its layout, the way
+// the sources do, which is useful to read objects of the repositories given
+// their repositoryID. The closer releases the files the repository keeps
+// open, like its siva file, or the directory a bundle or tarball is written
+// to, and must be called once it is no longer read.
+func OpenRepository(path string) (*git.Repository, io.Closer, error) {
+	repoType := (&baseSource{}).repositoryType(path)
+	if repoType == "" {
+		return nil, nil, errors.Errorf("%s is not a repository", path)
+	}
+	return openRepository(path, repoType)
+}
+
+// openRepository opens the repository at path according to its layout, see
+// OpenRepository.
+func openRepository(path, repoType string) (*git.Repository, io.Closer, error) {
+	switch repoType {
+	case repoTypeStandard, repoTypeBare, repoTypeGitDir:
+		if gleamfs.IsRemote(path) {
+			return withoutCloser(openRemote(path, repoType))
+		}
+		// PlainOpen already follows .git files and falls back to
+		// opening path itself as a bare repository.
+		return withoutCloser(git.PlainOpen(path))
+	case repoTypeWorktree:
+		return withoutCloser(openWorktree(path))
+	case repoTypeSiva:
+		return readSiva(path)
+	case repoTypeBundle:
+		return readBundle(path)
+	case repoTypeTarball:
+		return readTarball(path)
+	}
+	return nil, nil, errors.Errorf("unknown repository type %q", repoType)
+}
+
+// closerFunc is a function releasing what a repository keeps open.
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// withoutCloser returns a closer doing nothing with the repository, for the
+// layouts only keeping open the files being read.
+func withoutCloser(repo *git.Repository, err error) (*git.Repository, io.Closer, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, closerFunc(func() error { return nil }), nil
+}
+
+// openWorktree opens a linked worktree using the objects and references of
+// the main repository it was created from, since go-git has no support for
+// per-worktree git directories.
+func openWorktree(path string) (*git.Repository, error) {
+	commonDir, err := gitDirectory(path, repoTypeWorktree)
+	if err != nil {
+		return nil, err
+	}
+
+	sto, err := gitfs.NewStorage(repositoryFilesystem(commonDir))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create a new storage backend")
+	}
+
+	return git.Open(sto, repositoryFilesystem(path))
+}
+
+// openRemote opens a repository stored in HDFS or S3, reading its git
+// directory through Gleam's filesystems.
+func openRemote(path, repoType string) (*git.Repository, error) {
+	gitDir, err := gitDirectory(path, repoType)
+	if err != nil {
+		return nil, err
+	}
+
+	sto, err := gitfs.NewStorage(gleamfs.New(gitDir))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create a new storage backend")
+	}
+
+	// the worktree is never read, but go-git requires one for repositories
+	// not configured as bare.
+	return git.Open(sto, memfs.New())
+}
+
+// repositoryFilesystem returns a filesystem rooted at location, which can be
+// local or in any of the filesystems supported by Gleam.
+func repositoryFilesystem(location string) billy.Filesystem {
+	if gleamfs.IsRemote(location) {
+		return gleamfs.New(location)
+	}
+	return osfs.New(location)
+}
@@ -0,0 +1,231 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eiso/go-engine/gleamfs"
+	"github.com/pkg/errors"
+)
+
+// manifestExtensions are the extensions of the files read as manifests.
+var manifestExtensions = []string{".json", ".csv", ".txt"}
+
+// repoTypes are the repository types manifests can give.
+var repoTypes = map[string]bool{
+	repoTypeStandard: true,
+	repoTypeBare:     true,
+	repoTypeGitDir:   true,
+	repoTypeWorktree: true,
+	repoTypeSiva:     true,
+	repoTypeBundle:   true,
+	repoTypeTarball:  true,
+}
+
+// manifestColumns is the order of the columns of a CSV manifest without a
+// header row.
+var manifestColumns = []string{"path", "type", "url", "size", "priority"}
+
+// manifestEntry is a repository listed in a manifest file. Only the path is
+// required, the layout is detected when the type is not given.
+type manifestEntry struct {
+	Path     string `json:"path"`
+	Type     string `json:"type"`
+	URL      string `json:"url"`
+	Size     int64  `json:"size"`
+	Priority int    `json:"priority"`
+}
+
+// UnmarshalJSON allows JSON manifests to list plain paths as well as objects.
+func (e *manifestEntry) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &e.Path)
+	}
+
+	type entry manifestEntry
+	return json.Unmarshal(b, (*entry)(e))
+}
+
+// isManifest reports whether the file at path is read as a manifest by its
+// extension.
+func isManifest(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range manifestExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestRepos emits a shard info for every repository listed in the
+// manifest file at path, without crawling the filesystem. Repositories are
+// emitted by descending priority and then in the order of the manifest, so
+// the same manifest always produces the same shards.
+func (s *baseSource) manifestRepos(path string, emit shardEmitter) error {
+	entries, err := readManifest(path)
+	if err != nil {
+		return errors.Wrapf(err, "could not read manifest %s", path)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Priority > entries[j].Priority
+	})
+
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		repoPath := e.Path
+		if !filepath.IsAbs(repoPath) && !gleamfs.IsRemote(repoPath) {
+			dir, _ := gleamfs.Split(path)
+			repoPath = gleamfs.Join(dir, repoPath)
+		}
+
+		if seen[repoPath] {
+			log.Printf("skipping duplicated manifest entry: %s", repoPath)
+			continue
+		}
+		seen[repoPath] = true
+
+		repoType := e.Type
+		if repoType == "" {
+			repoType = s.repositoryType(repoPath)
+		}
+		if repoType == "" {
+			log.Printf("skipping manifest entry: %s is not a repository", repoPath)
+			continue
+		}
+
+		shard := s.newShardInfo(repoPath, repoType)
+		shard.RepoURL = e.URL
+		shard.RepoSize = e.Size
+		if err := emit(shard); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readManifest parses a manifest file. Files ending in .json hold a list of
+// paths or objects, files ending in .csv hold one repository per record with
+// an optional header row, and files ending in .txt hold one path per line.
+func readManifest(path string) ([]manifestEntry, error) {
+	f, err := gleamfs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []manifestEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		entries, err = readJSONManifest(b)
+	case ".csv":
+		entries, err = readCSVManifest(b)
+	case ".txt":
+		entries, err = readLinesManifest(b)
+	default:
+		return nil, errors.Errorf("unknown manifest format %s", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.Type != "" && !repoTypes[e.Type] {
+			return nil, errors.Errorf("unknown repository type %q of %s", e.Type, e.Path)
+		}
+	}
+	return entries, nil
+}
+
+func readJSONManifest(b []byte) ([]manifestEntry, error) {
+	var entries []manifestEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, errors.Wrap(err, "could not decode JSON manifest")
+	}
+
+	for i, e := range entries {
+		if e.Path == "" {
+			return nil, errors.Errorf("entry %d has no path", i)
+		}
+	}
+	return entries, nil
+}
+
+func readCSVManifest(b []byte) ([]manifestEntry, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode CSV manifest")
+	}
+
+	columns := manifestColumns
+	if len(records) > 0 && strings.EqualFold(records[0][0], "path") {
+		columns = records[0]
+		records = records[1:]
+	}
+
+	var entries []manifestEntry
+	for i, record := range records {
+		var e manifestEntry
+		for j, value := range record {
+			if j >= len(columns) || value == "" {
+				continue
+			}
+
+			var err error
+			switch strings.ToLower(columns[j]) {
+			case "path":
+				e.Path = value
+			case "type":
+				e.Type = value
+			case "url":
+				e.URL = value
+			case "size":
+				e.Size, err = strconv.ParseInt(value, 10, 64)
+			case "priority":
+				e.Priority, err = strconv.Atoi(value)
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s in record %d", columns[j], i+1)
+			}
+		}
+
+		if e.Path == "" {
+			return nil, errors.Errorf("record %d has no path", i+1)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func readLinesManifest(b []byte) ([]manifestEntry, error) {
+	var entries []manifestEntry
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, manifestEntry{Path: line})
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,353 @@
+package git
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/chrislusf/gleam/pb"
+	"github.com/eiso/go-engine/gleamfs"
+	"github.com/pkg/errors"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// repoSize is the estimated size of a repository.
+type repoSize struct {
+	// Bytes is the size of the siva file, the packfiles or the size given
+	// in the manifest, and is what partitions are balanced by.
+	Bytes int64
+	// Objects is the number of packed objects, when known.
+	Objects int64
+}
+
+// writePartitioned assigns the shards to partitions with a greedy bin
+// packing, biggest repository first into the least loaded partition, and
+// writes every shard info keyed by its partition.
+func (s *baseSource) writePartitioned(shards []*shardInfo, out io.Writer, stats *pb.InstructionStat) error {
+	partitionCount := s.partitionCount
+	if partitionCount < 1 {
+		partitionCount = 1
+	}
+
+	var sized []sizedShard
+	for _, shard := range shards {
+		size := repositorySize(shard)
+		shard.RepoSize = size.Bytes
+
+		split := s.splitShard(shard, partitionCount)
+		for _, part := range split {
+			sized = append(sized, sizedShard{
+				shard: part,
+				size: repoSize{
+					Bytes:   part.RepoSize,
+					Objects: size.Objects / int64(len(split)),
+				},
+			})
+		}
+	}
+
+	// the stable sort keeps the assignment deterministic between runs
+	sort.SliceStable(sized, func(i, j int) bool {
+		return sized[i].size.Bytes > sized[j].size.Bytes
+	})
+
+	bins := make(partitionHeap, partitionCount)
+	for i := range bins {
+		bins[i] = &partitionLoad{index: i}
+	}
+	heap.Init(&bins)
+
+	for _, sh := range sized {
+		bin := bins[0]
+		bin.bytes += sh.size.Bytes
+		bin.objects += sh.size.Objects
+		bin.shards++
+		heap.Fix(&bins, 0)
+
+		if err := s.writeShardInfo(out, stats, sh.shard, int64(bin.index)); err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(bins, func(i, j int) bool { return bins[i].index < bins[j].index })
+	for _, bin := range bins {
+		log.Printf("partition %d: %d shards, %d bytes, %d objects",
+			bin.index, bin.shards, bin.bytes, bin.objects)
+	}
+	return nil
+}
+
+// splitShard splits a shard bigger than the split size into several ones,
+// at most one per partition. When all the commits are read the history is
+// split in disjoint ranges, otherwise the references of the repository are
+// dealt among the shards.
+func (s *baseSource) splitShard(shard *shardInfo, partitionCount int) []*shardInfo {
+	if s.splitSize <= 0 || shard.RepoSize <= s.splitSize || shard.DataType == "repositories" {
+		return []*shardInfo{shard}
+	}
+
+	n := int((shard.RepoSize + s.splitSize - 1) / s.splitSize)
+	if n > partitionCount {
+		n = partitionCount
+	}
+	if n < 2 {
+		return []*shardInfo{shard}
+	}
+
+	if shard.AllCommits {
+		split, err := splitHistory(shard, n)
+		if err != nil {
+			log.Printf("not splitting repository %s: %s", shard.RepoPath, err)
+			return []*shardInfo{shard}
+		}
+		return split
+	}
+
+	refs := shard.FilterRefs
+	if len(refs) == 0 {
+		repo, closer, err := openRepository(shard.RepoPath, shard.RepoType)
+		if err == nil {
+			refs, err = referenceNames(repo)
+			closer.Close()
+		}
+		if err != nil {
+			log.Printf("not splitting repository %s: %s", shard.RepoPath, err)
+			return []*shardInfo{shard}
+		}
+	}
+	if n > len(refs) {
+		n = len(refs)
+	}
+	if n < 2 {
+		return []*shardInfo{shard}
+	}
+
+	log.Printf("splitting repository %s of %d bytes into %d shards", shard.RepoPath, shard.RepoSize, n)
+	split := make([]*shardInfo, n)
+	for i := range split {
+		part := *shard
+		part.RepoSize = shard.RepoSize / int64(n)
+		part.FilterRefs = nil
+		split[i] = &part
+	}
+	for i, ref := range refs {
+		part := split[i%n]
+		part.FilterRefs = append(part.FilterRefs, ref)
+	}
+	return split
+}
+
+// splitHistory splits the history of the shard in n ranges delimited by
+// commits of the first parent chain of HEAD, or of the first reference that
+// can be resolved. Given the boundaries b1..bn-1, from newest to
+// oldest, the first shard reads the references excluding b1, and every
+// other shard i reads bi excluding bi+1. Every commit belongs to exactly one
+// range since the history of each boundary contains the history of the next.
+func splitHistory(shard *shardInfo, n int) ([]*shardInfo, error) {
+	repo, closer, err := openRepository(shard.RepoPath, shard.RepoType)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open repository")
+	}
+	defer closer.Close()
+
+	candidates := shard.FilterRefs
+	if len(candidates) == 0 {
+		names, err := referenceNames(repo)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append([]string{"HEAD"}, names...)
+	}
+
+	var tip *object.Commit
+	for _, name := range candidates {
+		ref, err := repo.Reference(plumbing.ReferenceName(name), true)
+		if err != nil || ref.Name().IsTag() {
+			continue
+		}
+		if tip, err = repo.CommitObject(ref.Hash()); err == nil {
+			break
+		}
+	}
+	if tip == nil {
+		return nil, errors.New("could not find a commit to split the history from")
+	}
+
+	var chain []plumbing.Hash
+	for c := tip; ; {
+		chain = append(chain, c.Hash)
+		if c.NumParents() == 0 {
+			break
+		}
+		if c, err = c.Parents().Next(); err != nil {
+			return nil, errors.Wrap(err, "could not walk the first parent chain")
+		}
+	}
+	if n > len(chain) {
+		n = len(chain)
+	}
+	if n < 2 {
+		return []*shardInfo{shard}, nil
+	}
+
+	log.Printf("splitting history of %s, %d commits long, in %d ranges", shard.RepoPath, len(chain), n)
+	split := make([]*shardInfo, n)
+	for i := range split {
+		part := *shard
+		part.RepoSize = shard.RepoSize / int64(n)
+		if i > 0 {
+			part.FilterRefs = []string{chain[i*len(chain)/n].String()}
+		}
+		part.ExcludeRefs = append([]string(nil), shard.ExcludeRefs...)
+		if i < n-1 {
+			part.ExcludeRefs = append(part.ExcludeRefs, chain[(i+1)*len(chain)/n].String())
+		}
+		split[i] = &part
+	}
+	return split, nil
+}
+
+// referenceNames lists the names of all the references of the repository.
+func referenceNames(repo *git.Repository) ([]string, error) {
+	iter, err := repo.References()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch references from repository")
+	}
+	defer iter.Close()
+
+	var names []string
+	for {
+		ref, err := iter.Next()
+		if err == io.EOF {
+			return names, nil
+		} else if err != nil {
+			return nil, err
+		}
+		names = append(names, ref.Name().String())
+	}
+}
+
+// repositorySize estimates the size of the repository of the shard. Sizes
+// given in a manifest take precedence over the ones found on disk.
+func repositorySize(shard *shardInfo) repoSize {
+	if shard.RepoSize > 0 {
+		return repoSize{Bytes: shard.RepoSize}
+	}
+
+	switch shard.RepoType {
+	case repoTypeSiva, repoTypeBundle, repoTypeTarball:
+		f, err := gleamfs.Open(shard.RepoPath)
+		if err != nil {
+			return repoSize{}
+		}
+		defer f.Close()
+		return repoSize{Bytes: f.Size()}
+	}
+
+	gitDir, err := gitDirectory(shard.RepoPath, shard.RepoType)
+	if err != nil {
+		return repoSize{}
+	}
+	return packedSize(gleamfs.Join(gitDir, "objects", "pack"))
+}
+
+// packedSize sums the size of the packfiles and the number of objects in
+// their indexes. Loose objects are not taken into account.
+func packedSize(dir string) repoSize {
+	var size repoSize
+	if !gleamfs.IsDir(dir) {
+		return size
+	}
+
+	files, err := gleamfs.List(dir)
+	if err != nil {
+		return size
+	}
+
+	for _, file := range files {
+		switch {
+		case strings.HasSuffix(file.Location, ".pack"):
+			f, err := gleamfs.Open(file.Location)
+			if err != nil {
+				continue
+			}
+			size.Bytes += f.Size()
+			f.Close()
+		case strings.HasSuffix(file.Location, ".idx"):
+			size.Objects += packIndexObjects(file.Location)
+		}
+	}
+	return size
+}
+
+// packIndexObjects returns the number of objects of a pack index, which is
+// the last entry of its fanout table.
+func packIndexObjects(path string) int64 {
+	f, err := gleamfs.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	// version 2 indexes start with a magic number and a version number
+	// before the fanout table, version 1 indexes start with the table.
+	header := make([]byte, 8)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return 0
+	}
+	offset := int64(255 * 4)
+	if string(header[:4]) == "\377tOc" {
+		offset += 8
+	}
+
+	last := make([]byte, 4)
+	if _, err := f.ReadAt(last, offset); err != nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint32(last))
+}
+
+type sizedShard struct {
+	shard *shardInfo
+	size  repoSize
+}
+
+// partitionLoad is the amount of data assigned to a partition.
+type partitionLoad struct {
+	index   int
+	bytes   int64
+	objects int64
+	shards  int
+}
+
+// partitionHeap keeps the least loaded partition on top.
+type partitionHeap []*partitionLoad
+
+func (h partitionHeap) Len() int { return len(h) }
+
+func (h partitionHeap) Less(i, j int) bool {
+	if h[i].bytes != h[j].bytes {
+		return h[i].bytes < h[j].bytes
+	}
+	if h[i].shards != h[j].shards {
+		return h[i].shards < h[j].shards
+	}
+	return h[i].index < h[j].index
+}
+
+func (h partitionHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *partitionHeap) Push(x interface{}) { *h = append(*h, x.(*partitionLoad)) }
+
+func (h *partitionHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -8,6 +8,9 @@ import (
 	git "gopkg.in/src-d/go-git.v4"
 )
 
+// Repositories reads the repositories found under path, which can be a
+// repository, a directory to crawl, or a manifest file listing the
+// repositories to read (see readManifest).
 func Repositories(path string, partitionCount int) *sourceRepositories {
 	return newGitRepositories(path, partitionCount)
 }
@@ -15,13 +18,14 @@ func Repositories(path string, partitionCount int) *sourceRepositories {
 type reader interface {
 	Read() (*util.Row, error)
 	ReadHeader() ([]string, error)
+	Schema() readers.Schema
 	Close() error
 }
 
 func (ds *shardInfo) NewReader(r *git.Repository, path string, flag bool) (reader, error) {
 	// .Repositories()
 	if ds.DataType == "repositories" {
-		repoReader, err := readers.NewRepositories(r, path)
+		repoReader, err := readers.NewRepositories(r, path, ds.RepoType, ds.RepoURL)
 		if err != nil {
 			repoReader.Close()
 			return nil, err
@@ -48,7 +52,7 @@ func (ds *shardInfo) NewReader(r *git.Repository, path string, flag bool) (reade
 		return nil, err
 	}
 
-	commitsReader, err := readers.NewCommits(r, path, refsIter, ds.AllCommits)
+	commitsReader, err := readers.NewCommits(r, path, refsIter, ds.AllCommits, ds.ExcludeRefs)
 	if err != nil {
 		refsReader.Close()
 		refsIter.Close()
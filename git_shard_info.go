@@ -6,16 +6,15 @@ import (
 	"io"
 	"log"
 	"os"
-	"path/filepath"
 
 	"github.com/chrislusf/gleam/gio"
 	"github.com/chrislusf/gleam/util"
+	"github.com/eiso/go-engine/gleamfs"
 	"github.com/eiso/go-engine/readers"
 	"github.com/pkg/errors"
 
 	sivafs "github.com/eiso/go-billy-siva"
 	"gopkg.in/src-d/go-billy.v4/memfs"
-	"gopkg.in/src-d/go-billy.v4/osfs"
 	git "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 )
@@ -31,10 +30,17 @@ type shardInfo struct {
 	Config     map[string]string
 	RepoPath   string
 	RepoType   string
+	RepoURL    string
+	RepoSize   int64
 	DataType   string
 	HasHeader  bool
 	FilterRefs []string
-	AllCommits bool
+	// ExcludeRefs are references or commit hashes whose history is not
+	// read, usually because another shard of the repository reads it.
+	ExcludeRefs []string
+	AllCommits  bool
+	// Conditions filter the rows read, see readers.Condition.
+	Conditions []readers.Condition
 }
 
 func (s *shardInfo) decode(b []byte) error {
@@ -53,7 +59,8 @@ func (s *shardInfo) encode() ([]byte, error) {
 
 func newReadShard(row []interface{}) error {
 	var s shardInfo
-	if err := s.decode(gio.ToBytes(row[0])); err != nil {
+	// the shard info is the last column, it may be keyed by its partition
+	if err := s.decode(gio.ToBytes(row[len(row)-1])); err != nil {
 		return err
 	}
 
@@ -67,23 +74,13 @@ func newReadShard(row []interface{}) error {
 func (s *shardInfo) ReadSplit() error {
 	log.Printf("started reading %s from: %s", s.DataType, s.RepoPath)
 
-	var repo *git.Repository
-	var err error
-	if s.RepoType == "standard" {
-		repo, err = git.PlainOpen(s.RepoPath)
-		if err != nil {
-			err = errors.Wrap(err, "could not open git repository")
-			log.Printf("skipping repository: %s due to %s", s.RepoPath, err)
-			return nil
-		}
-	} else if s.RepoType == "siva" {
-		repo, err = readSiva(s.RepoPath)
-		if err != nil {
-			err = errors.Wrap(err, "could not open siva git repository")
-			log.Printf("skipping repository: %s due to %s", s.RepoPath, err)
-			return nil
-		}
+	repo, closer, err := openRepository(s.RepoPath, s.RepoType)
+	if err != nil {
+		err = errors.Wrapf(err, "could not open %s git repository", s.RepoType)
+		log.Printf("skipping repository: %s due to %s", s.RepoPath, err)
+		return nil
 	}
+	defer closer.Close()
 
 	reader, err := s.NewReader(repo, s.RepoPath, false)
 	if err != nil {
@@ -117,6 +114,17 @@ func (s *shardInfo) ReadSplit() error {
 		} else if err != nil {
 			return errors.Wrap(err, "could not get next file")
 		}
+		if err := reader.Schema().Validate(row); err != nil {
+			return errors.Wrapf(err, "invalid %s row read from %s", s.DataType, s.RepoPath)
+		}
+		if len(s.Conditions) > 0 {
+			ok, err := reader.Schema().Match(row, s.Conditions)
+			if err != nil {
+				return errors.Wrapf(err, "could not filter %s rows", s.DataType)
+			} else if !ok {
+				continue
+			}
+		}
 		// Writing to stdout is how agents communicate.
 		if err := row.WriteTo(os.Stdout); err != nil {
 			return errors.Wrap(err, "could not write row to stdout")
@@ -124,25 +132,24 @@ func (s *shardInfo) ReadSplit() error {
 	}
 }
 
-func readSiva(origPath string) (*git.Repository, error) {
-	local := osfs.New(filepath.Dir(origPath))
+func readSiva(origPath string) (*git.Repository, io.Closer, error) {
+	dir, name := gleamfs.Split(origPath)
 	tmpFs := memfs.New()
 
-	origPath = filepath.Base(origPath)
-
-	fs, err := sivafs.NewFilesystem(local, origPath, tmpFs)
+	fs, err := sivafs.NewFilesystem(repositoryFilesystem(dir), name, tmpFs)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to create a siva filesystem")
+		return nil, nil, errors.Wrap(err, "unable to create a siva filesystem")
 	}
 
 	sto, err := filesystem.NewStorage(fs)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to create a new storage backend")
+		return nil, nil, errors.Wrap(err, "unable to create a new storage backend")
 	}
 
 	repository, err := git.Open(sto, tmpFs)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to open the git repository")
+		return nil, nil, errors.Wrap(err, "unable to open the git repository")
 	}
-	return repository, nil
+	// the siva file is opened on the first read and kept open until synced
+	return repository, closerFunc(fs.Sync), nil
 }
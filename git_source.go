@@ -4,13 +4,13 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"path/filepath"
 	"strings"
 
-	"github.com/chrislusf/gleam/filesystem"
 	"github.com/chrislusf/gleam/flow"
 	"github.com/chrislusf/gleam/pb"
 	"github.com/chrislusf/gleam/util"
+	"github.com/eiso/go-engine/gleamfs"
+	"github.com/eiso/go-engine/readers"
 	"github.com/pkg/errors"
 )
 
@@ -25,10 +25,19 @@ type baseSource struct {
 	prefix       string
 
 	// FIXME most probably it shouldn't be here
-	FilterRefs []string
-	allCommits bool
+	FilterRefs  []string
+	excludeRefs []string
+	conditions  []readers.Condition
+	allCommits  bool
+
+	// size aware partitioning, see PartitionBySize
+	partitionBySize bool
+	splitSize       int64
 }
 
+// shardEmitter receives every repository found by the source walkers.
+type shardEmitter func(*shardInfo) error
+
 type sourceRepositories struct {
 	baseSource
 }
@@ -50,12 +59,12 @@ type sourceBlobs struct {
 }
 
 func newGitRepositories(fsPath string, partitionCount int) *sourceRepositories {
-	base := filepath.Base(fsPath)
+	folder, base := gleamfs.Split(fsPath)
 
 	return &sourceRepositories{
 		baseSource: baseSource{
 			partitionCount: partitionCount,
-			folder:         filepath.Dir(fsPath),
+			folder:         folder,
 			fileBaseName:   base,
 			path:           fsPath,
 			hasWildcard:    strings.Contains(base, "**"),
@@ -64,96 +73,79 @@ func newGitRepositories(fsPath string, partitionCount int) *sourceRepositories {
 	}
 }
 
-// Find all standard & siva repositories in the directory
-func (s *baseSource) gitRepos(path string, out io.Writer, stats *pb.InstructionStat) error {
-
-	var virtualFiles []*filesystem.FileLocation
-	var err error
-
-	if !filesystem.IsDir(path) && s.isSivaFile(path) {
-		virtualFiles = append(virtualFiles, &filesystem.FileLocation{path})
-	} else {
-		virtualFiles, err = filesystem.List(path)
-		if err != nil {
-			return fmt.Errorf("Failed to list files in %s: %v", path, err)
-		}
+// Find all repositories in the directory, whatever their layout
+func (s *baseSource) gitRepos(path string, emit shardEmitter) error {
+	virtualFiles, err := gleamfs.List(path)
+	if err != nil {
+		return fmt.Errorf("Failed to list files in %s: %v", path, err)
 	}
 
 	for _, vf := range virtualFiles {
-		repoType := "standard"
-		if s.isSivaFile(vf.Location) {
-			repoType = "siva"
-		}
-
-		if !filesystem.IsDir(vf.Location) && repoType != "siva" {
-			continue
-		}
-
-		if !s.isStandardRepository(vf.Location) && repoType != "siva" {
-			err := s.gitRepos(vf.Location, out, stats)
-			if err != nil {
+		repoType := s.repositoryType(vf.Location)
+		if repoType == "" {
+			if !gleamfs.IsDir(vf.Location) {
+				continue
+			}
+			if err := s.gitRepos(vf.Location, emit); err != nil {
 				return err
 			}
 			continue
 		}
 
-		stats.OutputCounter++
-		s := &shardInfo{
-			RepoPath:   vf.Location,
-			RepoType:   repoType,
-			DataType:   s.prefix,
-			HasHeader:  s.showHeader,
-			FilterRefs: s.FilterRefs,
-			AllCommits: s.allCommits,
-		}
-
-		b, err := s.encode()
-		if err != nil {
-			return errors.Wrap(err, "could not encode shard info")
-		}
-
-		if err := util.NewRow(util.Now(), b).WriteTo(out); err != nil {
-			return errors.Wrap(err, "could not encode row")
+		if err := emit(s.newShardInfo(vf.Location, repoType)); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (s *baseSource) isStandardRepository(path string) bool {
-	p := filepath.Join(path, ".git")
-	ps, err := filesystem.Open(p)
-	defer ps.Close()
-	if err != nil {
-		return false
+func (s *baseSource) newShardInfo(path, repoType string) *shardInfo {
+	return &shardInfo{
+		RepoPath:    path,
+		RepoType:    repoType,
+		DataType:    s.prefix,
+		HasHeader:   s.showHeader,
+		FilterRefs:  s.FilterRefs,
+		ExcludeRefs: s.excludeRefs,
+		AllCommits:  s.allCommits,
+		Conditions:  s.conditions,
 	}
-	return true
 }
 
-func (s *baseSource) isSivaFile(path string) bool {
-	ext := filepath.Ext(path)
-	if ext != ".siva" {
-		return false
-	}
-	ps, err := filesystem.Open(path)
-	defer ps.Close()
+// writeShardInfo writes the encoded shard info as the last column of a row
+// that starts with the given keys.
+func (s *baseSource) writeShardInfo(out io.Writer, stats *pb.InstructionStat, shard *shardInfo, keys ...interface{}) error {
+	log.Printf("found %s repository: %s", shard.RepoType, shard.RepoPath)
+
+	stats.OutputCounter++
+	b, err := shard.encode()
 	if err != nil {
-		return false
-	}
-	if ps.Size() == 0 {
-		return false
+		return errors.Wrap(err, "could not encode shard info")
 	}
 
-	// open the siva file to see if it is a valid git repository
-	// if _, err := readSiva(path); err != nil {
-	// 	return false
-	// }
+	if err := util.NewRow(util.Now(), append(keys, b)...).WriteTo(out); err != nil {
+		return errors.Wrap(err, "could not encode row")
+	}
+	return nil
+}
 
-	return true
+// Schema returns the columns of the rows the source reads, so they can be
+// checked before running the flow.
+func (s *baseSource) Schema() (readers.Schema, error) {
+	return readers.SchemaOf(s.prefix)
 }
 
 func (s *baseSource) Generate(f *flow.Flow) *flow.Dataset {
-	return s.genShardInfos(f).RoundRobin(s.prefix, s.partitionCount).Map(s.prefix+".Read", regMapperReadShard)
+	shards := s.genShardInfos(f)
+	if s.partitionBySize {
+		// shard infos are keyed by the partition they were assigned to, and
+		// integer keys are partitioned by their value.
+		shards = shards.PartitionByKey(s.prefix, s.partitionCount)
+	} else {
+		shards = shards.RoundRobin(s.prefix, s.partitionCount)
+	}
+	return shards.Map(s.prefix+".Read", regMapperReadShard)
 }
 
 func (s *baseSource) genShardInfos(f *flow.Flow) *flow.Dataset {
@@ -161,47 +153,77 @@ func (s *baseSource) genShardInfos(f *flow.Flow) *flow.Dataset {
 		stats.InputCounter++
 		defer func() { log.Printf("Git repos: %d", stats.OutputCounter) }()
 
-		if s.hasWildcard {
-			return s.gitRepos(s.folder, out, stats)
-		}
-		if !filesystem.IsDir(s.path) && !s.isSivaFile(s.path) {
-			return fmt.Errorf("source can't be be a file: %s", s.path)
-		}
-		if !s.isStandardRepository(s.path) || s.isSivaFile(s.path) {
-			return s.gitRepos(s.path, out, stats)
-		}
-
-		repoType := "standard"
-		if s.isSivaFile(s.path) {
-			repoType = "siva"
+		if !s.partitionBySize {
+			return s.findRepos(func(shard *shardInfo) error {
+				return s.writeShardInfo(out, stats, shard)
+			})
 		}
 
-		stats.OutputCounter++
-		s := &shardInfo{
-			RepoPath:   s.path,
-			RepoType:   repoType,
-			DataType:   s.prefix,
-			HasHeader:  s.showHeader,
-			FilterRefs: s.FilterRefs,
-			AllCommits: s.allCommits,
-		}
-		b, err := s.encode()
+		var shards []*shardInfo
+		err := s.findRepos(func(shard *shardInfo) error {
+			shards = append(shards, shard)
+			return nil
+		})
 		if err != nil {
-			// TODO: improve error handling.
-			log.Fatalf("could not encode shard info: %v", err)
+			return err
 		}
-		return util.NewRow(util.Now(), b).WriteTo(out)
+		return s.writePartitioned(shards, out, stats)
 	})
 }
 
+// findRepos emits every repository of the source path.
+func (s *baseSource) findRepos(emit shardEmitter) error {
+	if s.hasWildcard {
+		return s.gitRepos(s.folder, emit)
+	}
+
+	repoType := s.repositoryType(s.path)
+	if repoType != "" {
+		return emit(s.newShardInfo(s.path, repoType))
+	}
+	if !gleamfs.IsDir(s.path) {
+		if !isManifest(s.path) {
+			return errors.Errorf("%s is not a repository, a directory or a manifest", s.path)
+		}
+		return s.manifestRepos(s.path, emit)
+	}
+	return s.gitRepos(s.path, emit)
+}
+
 func (s *sourceRepositories) WithHeaders() *sourceRepositories {
 	s.showHeader = true
 	return s
 }
 
+// Where filters the repositories read with the given conditions, which are
+// evaluated while reading the repositories.
+func (s *sourceRepositories) Where(conditions ...readers.Condition) *sourceRepositories {
+	s.conditions = append(s.conditions, conditions...)
+	return s
+}
+
+// PartitionBySize assigns repositories to partitions by their size instead
+// of round robin, so that big repositories are spread across partitions
+// rather than piled next to many small ones.
+func (s *sourceRepositories) PartitionBySize() *sourceRepositories {
+	s.partitionBySize = true
+	return s
+}
+
+// SplitLargerThan partitions by size and additionally splits repositories
+// bigger than size bytes into several shards, each one reading a subset of
+// the references of the repository. Repositories are never split when only
+// repositories are read.
+func (s *sourceRepositories) SplitLargerThan(size int64) *sourceRepositories {
+	s.partitionBySize = true
+	s.splitSize = size
+	return s
+}
+
 func (s *sourceRepositories) References() *sourceReferences {
 	newSource := s.baseSource
 	newSource.prefix = "references"
+	newSource.conditions = nil
 	return &sourceReferences{
 		baseSource: newSource,
 	}
@@ -212,9 +234,19 @@ func (s *sourceReferences) Filter(refs ...string) *sourceReferences {
 	return s
 }
 
+// Exclude skips the history reachable from the given references or commit
+// hashes when reading all the commits of the references, so that the
+// commits of a branch not merged into master can be read with
+// Filter("refs/heads/branch").Exclude("refs/heads/master").
+func (s *sourceReferences) Exclude(refs ...string) *sourceReferences {
+	s.baseSource.excludeRefs = refs
+	return s
+}
+
 func (s *sourceReferences) Commits() *sourceCommits {
 	newSource := s.baseSource
 	newSource.prefix = "commits"
+	newSource.conditions = nil
 	return &sourceCommits{
 		baseSource: newSource,
 	}
@@ -223,6 +255,7 @@ func (s *sourceReferences) Commits() *sourceCommits {
 func (s *sourceReferences) AllReferenceCommits() *sourceCommits {
 	newSource := s.baseSource
 	newSource.prefix = "commits"
+	newSource.conditions = nil
 	newSource.allCommits = true
 	return &sourceCommits{
 		baseSource: newSource,
@@ -234,9 +267,16 @@ func (s *sourceReferences) WithHeaders() *sourceReferences {
 	return s
 }
 
+// Where filters the references read, see sourceRepositories.Where.
+func (s *sourceReferences) Where(conditions ...readers.Condition) *sourceReferences {
+	s.conditions = append(s.conditions, conditions...)
+	return s
+}
+
 func (s *sourceCommits) Trees() *sourceTrees {
 	newSource := s.baseSource
 	newSource.prefix = "trees"
+	newSource.conditions = nil
 	return &sourceTrees{
 		baseSource: newSource,
 	}
@@ -247,9 +287,16 @@ func (s *sourceCommits) WithHeaders() *sourceCommits {
 	return s
 }
 
+// Where filters the commits read, see sourceRepositories.Where.
+func (s *sourceCommits) Where(conditions ...readers.Condition) *sourceCommits {
+	s.conditions = append(s.conditions, conditions...)
+	return s
+}
+
 func (s *sourceTrees) Blobs() *sourceBlobs {
 	newSource := s.baseSource
 	newSource.prefix = "blobs"
+	newSource.conditions = nil
 	return &sourceBlobs{
 		baseSource: newSource,
 	}
@@ -260,7 +307,19 @@ func (s *sourceTrees) WithHeaders() *sourceTrees {
 	return s
 }
 
+// Where filters the trees read, see sourceRepositories.Where.
+func (s *sourceTrees) Where(conditions ...readers.Condition) *sourceTrees {
+	s.conditions = append(s.conditions, conditions...)
+	return s
+}
+
 func (s *sourceBlobs) WithHeaders() *sourceBlobs {
 	s.showHeader = true
 	return s
 }
+
+// Where filters the blobs read, see sourceRepositories.Where.
+func (s *sourceBlobs) Where(conditions ...readers.Condition) *sourceBlobs {
+	s.conditions = append(s.conditions, conditions...)
+	return s
+}
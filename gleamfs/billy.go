@@ -0,0 +1,147 @@
+package gleamfs
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/chrislusf/gleam/filesystem"
+	billy "gopkg.in/src-d/go-billy.v4"
+)
+
+const writeFlags = os.O_WRONLY | os.O_RDWR | os.O_CREATE | os.O_TRUNC | os.O_APPEND
+
+var errIsDir = errors.New("is a directory")
+
+// Filesystem is a read-only go-billy filesystem rooted at a location of a
+// VFS, so go-git can read repositories stored in it.
+type Filesystem struct {
+	vfs  VFS
+	root string
+}
+
+// New returns a filesystem rooted at location using the default VFS.
+func New(root string) billy.Filesystem {
+	return NewWithVFS(Default, root)
+}
+
+// NewWithVFS returns a filesystem rooted at location using the given VFS.
+func NewWithVFS(vfs VFS, root string) billy.Filesystem {
+	return &Filesystem{vfs: vfs, root: strings.TrimSuffix(root, "/")}
+}
+
+func (fs *Filesystem) location(filename string) string {
+	return Join(fs.root, filename)
+}
+
+func (fs *Filesystem) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile only opens files for reading.
+func (fs *Filesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	if flag&writeFlags != 0 {
+		return nil, billy.ErrReadOnly
+	}
+
+	info, err := fs.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: errIsDir}
+	}
+
+	f, err := fs.vfs.Open(fs.location(filename))
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: err}
+	}
+	return &file{VirtualFile: f, name: filename}, nil
+}
+
+func (fs *Filesystem) Stat(filename string) (os.FileInfo, error) {
+	location := fs.location(filename)
+	if s, ok := fs.vfs.(Stater); ok {
+		return s.Stat(location)
+	}
+	return stat(fs.vfs, location)
+}
+
+func (fs *Filesystem) Lstat(filename string) (os.FileInfo, error) {
+	return fs.Stat(filename)
+}
+
+func (fs *Filesystem) ReadDir(dirname string) ([]os.FileInfo, error) {
+	files, err := fs.vfs.List(fs.location(dirname))
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: dirname, Err: err}
+	}
+
+	infos := make([]os.FileInfo, 0, len(files))
+	for _, f := range files {
+		_, name := Split(f.Location)
+		info, err := fs.Stat(fs.Join(dirname, name))
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (fs *Filesystem) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (fs *Filesystem) Chroot(p string) (billy.Filesystem, error) {
+	return &Filesystem{vfs: fs.vfs, root: fs.location(p)}, nil
+}
+
+func (fs *Filesystem) Root() string {
+	return fs.root
+}
+
+func (fs *Filesystem) Create(filename string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (fs *Filesystem) Rename(oldpath, newpath string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *Filesystem) Remove(filename string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *Filesystem) TempFile(dir, prefix string) (billy.File, error) {
+	return nil, billy.ErrReadOnly
+}
+
+func (fs *Filesystem) MkdirAll(filename string, perm os.FileMode) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *Filesystem) Symlink(target, link string) error {
+	return billy.ErrReadOnly
+}
+
+func (fs *Filesystem) Readlink(link string) (string, error) {
+	return "", billy.ErrNotSupported
+}
+
+// file is a read-only billy.File over a Gleam virtual file.
+type file struct {
+	filesystem.VirtualFile
+	name string
+}
+
+func (f *file) Name() string { return f.name }
+
+func (f *file) Write(p []byte) (int, error) { return 0, billy.ErrReadOnly }
+
+func (f *file) Truncate(size int64) error { return billy.ErrReadOnly }
+
+// Lock and Unlock do nothing since nothing is written.
+func (f *file) Lock() error   { return nil }
+func (f *file) Unlock() error { return nil }
@@ -0,0 +1,336 @@
+package gleamfs
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/chrislusf/gleam/filesystem"
+)
+
+const s3Prefix = "s3://"
+
+// Options for S3, set with filesystem.Set like the credentials Gleam uses.
+const (
+	// S3_ENDPOINT points the S3 client to an S3 compatible server instead
+	// of AWS, buckets are then addressed by path.
+	S3_ENDPOINT = filesystem.OptionName("s3_endpoint")
+	// S3_REGION is the region of the buckets, it defaults to AWS_REGION.
+	S3_REGION = filesystem.OptionName("s3_region")
+)
+
+// s3BlockSize is the size of the ranges requested to S3, and s3CachedBlocks
+// how many of them are kept per open file.
+const (
+	s3BlockSize    = 1 << 20
+	s3CachedBlocks = 16
+)
+
+// S3 is a VFS for s3:// locations. Directories are emulated with key
+// prefixes and files are read with ranged requests as they are accessed,
+// instead of being downloaded when opened.
+//
+// Gleam's own S3FileSystem is not used since it cannot list prefixes, takes
+// every location for a file, downloads whole objects to temporary files
+// when they are opened, which does not scale to siva files of several GB
+// read through a few random accesses, and only connects to AWS. Its
+// credentials options, AWS_ACCESS_KEY and AWS_SECRET_KEY, are used too.
+type S3 struct {
+	once   sync.Once
+	client *s3.S3
+	err    error
+}
+
+func isS3(location string) bool {
+	return strings.HasPrefix(location, s3Prefix)
+}
+
+func (fs *S3) svc() (*s3.S3, error) {
+	fs.once.Do(func() {
+		config := aws.NewConfig()
+		if key := filesystem.Option[filesystem.AWS_ACCESS_KEY]; key != "" {
+			config = config.WithCredentials(credentials.NewStaticCredentials(
+				key, filesystem.Option[filesystem.AWS_SECRET_KEY], ""))
+		}
+		if region := filesystem.Option[S3_REGION]; region != "" {
+			config = config.WithRegion(region)
+		}
+		if endpoint := filesystem.Option[S3_ENDPOINT]; endpoint != "" {
+			config = config.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+		}
+
+		sess, err := session.NewSession(config)
+		if err != nil {
+			fs.err = fmt.Errorf("failed to create S3 session: %v", err)
+			return
+		}
+		fs.client = s3.New(sess)
+	})
+	return fs.client, fs.err
+}
+
+func splitS3Location(location string) (bucket, key string, err error) {
+	if !isS3(location) {
+		return "", "", fmt.Errorf("location %s should start with %s", location, s3Prefix)
+	}
+
+	parts := strings.SplitN(location[len(s3Prefix):], "/", 2)
+	if len(parts) == 1 {
+		return parts[0], "", nil
+	}
+	return parts[0], strings.Trim(parts[1], "/"), nil
+}
+
+func (fs *S3) Open(location string) (filesystem.VirtualFile, error) {
+	info, err := fs.Stat(location)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", location)
+	}
+
+	svc, err := fs.svc()
+	if err != nil {
+		return nil, err
+	}
+	bucket, key, _ := splitS3Location(location)
+
+	return &s3File{
+		svc:    svc,
+		bucket: bucket,
+		key:    key,
+		size:   info.Size(),
+		blocks: make(map[int64][]byte),
+	}, nil
+}
+
+// List lists the objects and the common prefixes right under location.
+func (fs *S3) List(location string) ([]*filesystem.FileLocation, error) {
+	svc, err := fs.svc()
+	if err != nil {
+		return nil, err
+	}
+	bucket, key, err := splitS3Location(location)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := key
+	if prefix != "" {
+		prefix += "/"
+	}
+	base := s3Prefix + bucket + "/" + prefix
+
+	var locations []*filesystem.FileLocation
+	err = svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket:    aws.String(bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, p := range page.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(aws.StringValue(p.Prefix), prefix), "/")
+			locations = append(locations, &filesystem.FileLocation{Location: base + name})
+		}
+		for _, o := range page.Contents {
+			name := strings.TrimPrefix(aws.StringValue(o.Key), prefix)
+			if name == "" {
+				continue
+			}
+			locations = append(locations, &filesystem.FileLocation{Location: base + name})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %v", location, err)
+	}
+	return locations, nil
+}
+
+// IsDir reports whether there is any object under the location prefix.
+func (fs *S3) IsDir(location string) bool {
+	info, err := fs.Stat(location)
+	return err == nil && info.IsDir()
+}
+
+func (fs *S3) Stat(location string) (os.FileInfo, error) {
+	svc, err := fs.svc()
+	if err != nil {
+		return nil, err
+	}
+	bucket, key, err := splitS3Location(location)
+	if err != nil {
+		return nil, err
+	}
+	_, name := Split(location)
+
+	if key != "" {
+		head, err := svc.HeadObject(&s3.HeadObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err == nil {
+			return &fileInfo{name: name, size: aws.Int64Value(head.ContentLength)}, nil
+		}
+		if !isS3NotFound(err) {
+			return nil, &os.PathError{Op: "stat", Path: location, Err: err}
+		}
+	}
+
+	prefix := key
+	if prefix != "" {
+		prefix += "/"
+	}
+	list, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:  aws.String(bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(1),
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: location, Err: err}
+	}
+	if len(list.Contents) == 0 {
+		return nil, &os.PathError{Op: "stat", Path: location, Err: os.ErrNotExist}
+	}
+	return &fileInfo{name: name, dir: true}, nil
+}
+
+func isS3NotFound(err error) bool {
+	if aerr, ok := err.(awserr.RequestFailure); ok {
+		return aerr.StatusCode() == 404
+	}
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == s3.ErrCodeNoSuchKey || aerr.Code() == "NotFound"
+	}
+	return false
+}
+
+// s3File reads an object in blocks, keeping the most recently used ones.
+type s3File struct {
+	svc    *s3.S3
+	bucket string
+	key    string
+	size   int64
+	offset int64
+
+	mu     sync.Mutex
+	blocks map[int64][]byte
+	// lru are the indexes of the blocks kept, from the least recently used.
+	lru []int64
+}
+
+func (f *s3File) Size() int64 { return f.size }
+
+func (f *s3File) Close() error { return nil }
+
+func (f *s3File) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset %d", offset)
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *s3File) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		return 0, io.EOF
+	}
+
+	var n int
+	for n < len(p) && off < f.size {
+		block, err := f.block(off / s3BlockSize)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], block[off%s3BlockSize:])
+		n += c
+		off += int64(c)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *s3File) block(i int64) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if b, ok := f.blocks[i]; ok {
+		f.used(i)
+		return b, nil
+	}
+
+	start := i * s3BlockSize
+	end := start + s3BlockSize - 1
+	if end >= f.size {
+		end = f.size - 1
+	}
+
+	resp, err := f.svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(f.bucket),
+		Key:    aws.String(f.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read s3://%s/%s: %v", f.bucket, f.key, err)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read s3://%s/%s: %v", f.bucket, f.key, err)
+	}
+	// the object may have changed since it was opened
+	if int64(len(b)) != end-start+1 {
+		return nil, fmt.Errorf("failed to read s3://%s/%s: got %d bytes at %d instead of %d",
+			f.bucket, f.key, len(b), start, end-start+1)
+	}
+
+	if len(f.lru) >= s3CachedBlocks {
+		delete(f.blocks, f.lru[0])
+		f.lru = f.lru[1:]
+	}
+	f.blocks[i] = b
+	f.lru = append(f.lru, i)
+	return b, nil
+}
+
+// used moves the block i to the end of the blocks kept, as the most
+// recently used.
+func (f *s3File) used(i int64) {
+	for j, k := range f.lru {
+		if k == i {
+			copy(f.lru[j:], f.lru[j+1:])
+			f.lru[len(f.lru)-1] = i
+			return
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package gleamfs
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chrislusf/gleam/filesystem"
+)
+
+// fakeS3 is an S3 compatible server holding objects in memory, enough for
+// the requests the S3 VFS does.
+type fakeS3 struct {
+	objects map[string][]byte
+	// truncated objects are served shorter than their size
+	truncated map[string]int
+
+	mu     sync.Mutex
+	ranges []string
+}
+
+type listBucketResult struct {
+	XMLName        xml.Name `xml:"ListBucketResult"`
+	Name           string
+	Prefix         string
+	KeyCount       int
+	IsTruncated    bool
+	Contents       []listContent
+	CommonPrefixes []listPrefix
+}
+
+type listContent struct {
+	Key  string
+	Size int
+}
+
+type listPrefix struct {
+	Prefix string
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) == 1 {
+		s.list(w, parts[0], r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter"))
+		return
+	}
+
+	data, ok := s.objects[parts[0]+"/"+parts[1]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		return
+	}
+
+	var start, end int
+	rng := r.Header.Get("Range")
+	if _, err := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.ranges = append(s.ranges, rng)
+	s.mu.Unlock()
+
+	if n, ok := s.truncated[parts[0]+"/"+parts[1]]; ok {
+		data = data[:n]
+	}
+	if end >= len(data) {
+		end = len(data) - 1
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(data[start : end+1])
+}
+
+func (s *fakeS3) list(w http.ResponseWriter, bucket, prefix, delimiter string) {
+	result := listBucketResult{Name: bucket, Prefix: prefix}
+	seen := make(map[string]bool)
+
+	var keys []string
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !strings.HasPrefix(k, bucket+"/"+prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(k, bucket+"/")
+		rest := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			p := prefix + rest[:i+1]
+			if !seen[p] {
+				seen[p] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, listPrefix{Prefix: p})
+			}
+			continue
+		}
+		result.Contents = append(result.Contents, listContent{Key: key, Size: len(s.objects[k])})
+	}
+	result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (s *fakeS3) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+// newTestS3 serves the objects, keyed by bucket/key, and returns an S3 VFS
+// reading them.
+func newTestS3(t *testing.T, server *fakeS3) *S3 {
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	options := map[filesystem.OptionName]string{
+		S3_ENDPOINT:               ts.URL,
+		S3_REGION:                 "us-east-1",
+		filesystem.AWS_ACCESS_KEY: "key",
+		filesystem.AWS_SECRET_KEY: "secret",
+	}
+	for name, value := range options {
+		old, ok := filesystem.Option[name]
+		filesystem.Set(name, value)
+		name := name
+		t.Cleanup(func() {
+			if ok {
+				filesystem.Option[name] = old
+			} else {
+				delete(filesystem.Option, name)
+			}
+		})
+	}
+	return &S3{}
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.New(rand.NewSource(int64(n))).Read(b)
+	return b
+}
+
+func TestS3ListAndStat(t *testing.T) {
+	fs := newTestS3(t, &fakeS3{objects: map[string][]byte{
+		"bucket/repos/a.siva":     []byte("siva"),
+		"bucket/repos/bare/HEAD":  []byte("ref: refs/heads/master\n"),
+		"bucket/repos/bare/refs/": nil,
+	}})
+
+	files, err := fs.List("s3://bucket/repos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locations []string
+	for _, f := range files {
+		locations = append(locations, f.Location)
+	}
+	expected := []string{"s3://bucket/repos/bare", "s3://bucket/repos/a.siva"}
+	if fmt.Sprint(locations) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, locations)
+	}
+
+	if !fs.IsDir("s3://bucket/repos/bare") {
+		t.Error("expected s3://bucket/repos/bare to be a directory")
+	}
+	if fs.IsDir("s3://bucket/repos/a.siva") {
+		t.Error("expected s3://bucket/repos/a.siva to be a file")
+	}
+
+	info, err := fs.Stat("s3://bucket/repos/a.siva")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 4 || info.Name() != "a.siva" {
+		t.Errorf("expected a.siva of 4 bytes, got %s of %d bytes", info.Name(), info.Size())
+	}
+
+	if _, err := fs.Stat("s3://bucket/missing"); err == nil {
+		t.Error("expected an error for a missing object")
+	}
+}
+
+func TestS3Read(t *testing.T) {
+	data := randomBytes(2*s3BlockSize + 1000)
+	server := &fakeS3{objects: map[string][]byte{"bucket/a.siva": data}}
+	fs := newTestS3(t, server)
+
+	f, err := fs.Open("s3://bucket/a.siva")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.Size() != int64(len(data)) {
+		t.Fatalf("expected a size of %d, got %d", len(data), f.Size())
+	}
+	if len(server.requests()) != 0 {
+		t.Errorf("expected no read when opening, got %v", server.requests())
+	}
+
+	// across the first two blocks
+	p := make([]byte, 100)
+	off := int64(s3BlockSize - 50)
+	if _, err := f.(io.ReaderAt).ReadAt(p, off); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p, data[off:off+100]) {
+		t.Error("unexpected content across blocks")
+	}
+
+	all := new(bytes.Buffer)
+	if _, err := io.Copy(all, f); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(all.Bytes(), data) {
+		t.Error("unexpected content of the whole file")
+	}
+	if n := len(server.requests()); n != 3 {
+		t.Errorf("expected every block to be requested once, got %d requests", n)
+	}
+}
+
+func TestS3ShortRead(t *testing.T) {
+	fs := newTestS3(t, &fakeS3{
+		objects:   map[string][]byte{"bucket/a.siva": randomBytes(1000)},
+		truncated: map[string]int{"bucket/a.siva": 500},
+	})
+
+	f, err := fs.Open("s3://bucket/a.siva")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	p := make([]byte, 100)
+	if _, err := f.(io.ReaderAt).ReadAt(p, 800); err == nil {
+		t.Error("expected an error reading past what the server returns")
+	}
+}
+
+func TestS3BlockCache(t *testing.T) {
+	server := &fakeS3{objects: map[string][]byte{
+		"bucket/a.siva": randomBytes((s3CachedBlocks + 1) * s3BlockSize),
+	}}
+	fs := newTestS3(t, server)
+
+	f, err := fs.Open("s3://bucket/a.siva")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	read := func(block int64) {
+		p := make([]byte, 1)
+		if _, err := f.(io.ReaderAt).ReadAt(p, block*s3BlockSize); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := int64(0); i < s3CachedBlocks; i++ {
+		read(i)
+	}
+	// block 0 is used again, so block 1 is the one evicted for the last
+	read(0)
+	read(s3CachedBlocks)
+	read(0)
+	if n := len(server.requests()); n != s3CachedBlocks+1 {
+		t.Errorf("expected %d requests, got %d", s3CachedBlocks+1, n)
+	}
+
+	read(1)
+	if n := len(server.requests()); n != s3CachedBlocks+2 {
+		t.Errorf("expected block 1 to be requested again, got %d requests", n)
+	}
+}
@@ -0,0 +1,167 @@
+// Package gleamfs gives access to the repositories stored in any of the
+// filesystems supported by Gleam, local, HDFS or S3, both through Gleam's
+// own virtual file API and as a go-billy filesystem usable by go-git.
+package gleamfs
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/gleam/filesystem"
+)
+
+// VFS is a virtual filesystem like the ones of Gleam's filesystem package.
+type VFS interface {
+	Open(location string) (filesystem.VirtualFile, error)
+	List(location string) ([]*filesystem.FileLocation, error)
+	IsDir(location string) bool
+}
+
+// Stater is implemented by filesystems able to tell the size of a file
+// without opening it.
+type Stater interface {
+	Stat(location string) (os.FileInfo, error)
+}
+
+// Default is used by the package level functions. It relies on Gleam for
+// local and HDFS locations, and on its own S3 client for s3:// locations
+// since Gleam can neither list S3 prefixes nor read objects without
+// downloading them.
+var Default VFS = &gleamVFS{s3: &S3{}}
+
+// Open opens the file at location using the default filesystem.
+func Open(location string) (filesystem.VirtualFile, error) {
+	return Default.Open(location)
+}
+
+// List lists the files in the directory at location using the default
+// filesystem.
+func List(location string) ([]*filesystem.FileLocation, error) {
+	return Default.List(location)
+}
+
+// IsDir reports whether location is a directory in the default filesystem.
+func IsDir(location string) bool {
+	return Default.IsDir(location)
+}
+
+// IsRemote reports whether the location is not in the local filesystem.
+func IsRemote(location string) bool {
+	return strings.Contains(location, "://")
+}
+
+// Split splits a location into its directory and base name, keeping the
+// scheme of remote locations intact.
+func Split(location string) (dir, base string) {
+	i := strings.LastIndex(location, "/")
+	if i < 0 {
+		return ".", location
+	}
+	if i == 0 || strings.HasSuffix(location[:i], ":/") {
+		return location[:i+1], location[i+1:]
+	}
+	return location[:i], location[i+1:]
+}
+
+// Join joins a location with the given path elements, keeping the scheme of
+// remote locations intact.
+func Join(location string, elem ...string) string {
+	i := strings.Index(location, "://")
+	if i < 0 {
+		return filepath.Join(append([]string{location}, elem...)...)
+	}
+
+	scheme, rest := location[:i+3], location[i+3:]
+	return scheme + path.Join(append([]string{rest}, elem...)...)
+}
+
+type gleamVFS struct {
+	s3 *S3
+}
+
+func (fs *gleamVFS) Open(location string) (filesystem.VirtualFile, error) {
+	if isS3(location) {
+		return fs.s3.Open(location)
+	}
+	return filesystem.Open(location)
+}
+
+func (fs *gleamVFS) List(location string) ([]*filesystem.FileLocation, error) {
+	if isS3(location) {
+		return fs.s3.List(location)
+	}
+	return filesystem.List(location)
+}
+
+// IsDir does not rely on Gleam for local and HDFS locations since it logs,
+// or even exits, when the location does not exist.
+func (fs *gleamVFS) IsDir(location string) bool {
+	info, err := fs.Stat(location)
+	return err == nil && info.IsDir()
+}
+
+func (fs *gleamVFS) Stat(location string) (os.FileInfo, error) {
+	if isS3(location) {
+		return fs.s3.Stat(location)
+	}
+
+	f, err := fs.Open(location)
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: location, Err: os.ErrNotExist}
+	}
+	defer f.Close()
+
+	// the files of Gleam's local and HDFS filesystems embed the os.File or
+	// the HDFS reader, which already know their file info.
+	switch f := f.(type) {
+	case interface {
+		Stat() (os.FileInfo, error)
+	}:
+		return f.Stat()
+	case interface {
+		Stat() os.FileInfo
+	}:
+		return f.Stat(), nil
+	}
+
+	_, name := Split(location)
+	return &fileInfo{name: name, size: f.Size()}, nil
+}
+
+// stat builds the file info of location from what any VFS can tell.
+func stat(fs VFS, location string) (os.FileInfo, error) {
+	_, name := Split(location)
+
+	f, err := fs.Open(location)
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: location, Err: os.ErrNotExist}
+	}
+	defer f.Close()
+
+	if fs.IsDir(location) {
+		return &fileInfo{name: name, dir: true}, nil
+	}
+	return &fileInfo{name: name, size: f.Size()}, nil
+}
+
+type fileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+func (fi *fileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
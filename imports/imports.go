@@ -0,0 +1,145 @@
+// Package imports extracts the dependencies of projects, from the imports
+// of their source files and the dependencies declared in their manifests.
+package imports
+
+import (
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Ecosystems of the dependencies.
+const (
+	Go         = "go"
+	Python     = "python"
+	JavaScript = "javascript"
+	Java       = "java"
+)
+
+// Dependency is a package imported or declared.
+type Dependency struct {
+	// Ecosystem is the language of the package, like go.
+	Ecosystem string
+	// Name is the name of the package: the import path of Go packages, the
+	// module of Python imports, the npm package of JavaScript imports, and
+	// the class or package of Java imports. Maven dependencies are named
+	// groupId:artifactId.
+	Name string
+	// Version is the version or version range declared in manifests,
+	// empty for imports.
+	Version string
+}
+
+// Extract returns the dependencies of a file: the ones declared if it is a
+// manifest, see IsManifest, or the ones imported otherwise, by the
+// language, usually classified by enry.
+func Extract(filename, lang string, content []byte) []Dependency {
+	if deps, ok := FromManifest(filename, content); ok {
+		return deps
+	}
+	return FromSource(lang, content)
+}
+
+// FromSource returns the packages imported by the source code, in the
+// order they are imported. Relative imports of JavaScript and Python,
+// which are part of the project, are left out.
+func FromSource(lang string, content []byte) []Dependency {
+	switch lang {
+	case "Go":
+		return goImports(content)
+	case "Python":
+		return pythonImports(content)
+	case "JavaScript", "JSX", "TypeScript", "TSX", "Vue":
+		return javaScriptImports(content)
+	case "Java":
+		return javaImports(content)
+	}
+	return nil
+}
+
+func goImports(content []byte) []Dependency {
+	// files with errors may still have their imports parsed
+	f, _ := parser.ParseFile(token.NewFileSet(), "", content, parser.ImportsOnly)
+	if f == nil {
+		return nil
+	}
+
+	var deps []Dependency
+	for _, spec := range f.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		deps = append(deps, Dependency{Ecosystem: Go, Name: path})
+	}
+	return deps
+}
+
+var (
+	pythonImport     = regexp.MustCompile(`^\s*import\s+([\w.][\w.\s,]*)`)
+	pythonFromImport = regexp.MustCompile(`^\s*from\s+([\w.]+)\s+import\b`)
+)
+
+func pythonImports(content []byte) []Dependency {
+	var deps []Dependency
+	for _, line := range strings.Split(string(content), "\n") {
+		if m := pythonFromImport.FindStringSubmatch(line); m != nil {
+			if !strings.HasPrefix(m[1], ".") {
+				deps = append(deps, Dependency{Ecosystem: Python, Name: m[1]})
+			}
+			continue
+		}
+
+		m := pythonImport.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		for _, module := range strings.Split(m[1], ",") {
+			// import numpy as np
+			if fields := strings.Fields(module); len(fields) > 0 {
+				deps = append(deps, Dependency{Ecosystem: Python, Name: fields[0]})
+			}
+		}
+	}
+	return deps
+}
+
+var javaScriptImport = regexp.MustCompile(
+	`(?:\b(?:import|export)\s[^'"]*?\bfrom\s*|\bimport\s*|\b(?:require|import)\s*\(\s*)['"]([^'"\s]+)['"]`)
+
+func javaScriptImports(content []byte) []Dependency {
+	var deps []Dependency
+	for _, m := range javaScriptImport.FindAllSubmatch(content, -1) {
+		if name := npmPackage(string(m[1])); name != "" {
+			deps = append(deps, Dependency{Ecosystem: JavaScript, Name: name})
+		}
+	}
+	return deps
+}
+
+// npmPackage returns the package of the module imported, like lodash for
+// lodash/fp or @babel/core for @babel/core/lib/parse, or nothing for
+// relative imports.
+func npmPackage(module string) string {
+	if strings.HasPrefix(module, ".") || strings.HasPrefix(module, "/") {
+		return ""
+	}
+
+	parts := strings.SplitN(module, "/", 3)
+	if strings.HasPrefix(module, "@") && len(parts) > 1 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+var javaImport = regexp.MustCompile(`(?m)^\s*import\s+(?:static\s+)?([\w.]+?)(?:\.\*)?\s*;`)
+
+func javaImports(content []byte) []Dependency {
+	var deps []Dependency
+	for _, m := range javaImport.FindAllSubmatch(content, -1) {
+		deps = append(deps, Dependency{Ecosystem: Java, Name: string(m[1])})
+	}
+	return deps
+}
@@ -0,0 +1,72 @@
+package imports
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromSource(t *testing.T) {
+	cases := []struct {
+		lang, content string
+		expected      []Dependency
+	}{
+		{
+			"Go", "package main\n\nimport (\n\t\"fmt\"\n\tgit \"gopkg.in/src-d/go-git.v4\"\n)\n\nimport _ \"net/http/pprof\"\n",
+			[]Dependency{{Go, "fmt", ""}, {Go, "gopkg.in/src-d/go-git.v4", ""}, {Go, "net/http/pprof", ""}},
+		},
+		{
+			"Go", "package main\n\nimport \"os\"\n\nfunc main() {",
+			[]Dependency{{Go, "os", ""}},
+		},
+		{
+			"Python",
+			"import os, sys\nimport numpy as np\nfrom collections import OrderedDict\nfrom . import sibling\nfrom .models import User\n  import json\n# import commented\n",
+			[]Dependency{{Python, "os", ""}, {Python, "sys", ""}, {Python, "numpy", ""}, {Python, "collections", ""}, {Python, "json", ""}},
+		},
+		{
+			"JavaScript",
+			"import React from 'react'\nimport { map } from \"lodash/fp\"\nimport './styles.css'\nconst core = require('@babel/core/lib/parse')\nconst local = require('../local')\nexport { a } from 'exported'\nconst lazy = import('lazy')\n",
+			[]Dependency{
+				{JavaScript, "react", ""}, {JavaScript, "lodash", ""}, {JavaScript, "@babel/core", ""},
+				{JavaScript, "exported", ""}, {JavaScript, "lazy", ""},
+			},
+		},
+		{
+			"TypeScript", "import * as fs from 'fs';\n",
+			[]Dependency{{JavaScript, "fs", ""}},
+		},
+		{
+			"Java",
+			"package a;\n\nimport java.util.List;\nimport static org.junit.Assert.assertEquals;\nimport com.google.common.collect.*;\n",
+			[]Dependency{{Java, "java.util.List", ""}, {Java, "org.junit.Assert.assertEquals", ""}, {Java, "com.google.common.collect", ""}},
+		},
+		{"Ruby", "require 'json'\n", nil},
+	}
+
+	for _, c := range cases {
+		got := FromSource(c.lang, []byte(c.content))
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("expected the imports %v of %s\n%s\ngot %v", c.expected, c.lang, c.content, got)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	cases := []struct {
+		filename, lang, content string
+		expected                []Dependency
+	}{
+		{"src/requirements.txt", "Text", "flask==1.0\n", []Dependency{{Python, "flask", "==1.0"}}},
+		{"main.py", "Python", "import flask\n", []Dependency{{Python, "flask", ""}}},
+		// manifests which cannot be parsed are not read as source files
+		{"package.json", "JSON", "{", nil},
+		{"README.md", "Markdown", "import flask\n", nil},
+	}
+
+	for _, c := range cases {
+		got := Extract(c.filename, c.lang, []byte(c.content))
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("expected the dependencies %v of %s, got %v", c.expected, c.filename, got)
+		}
+	}
+}
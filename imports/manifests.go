@@ -0,0 +1,191 @@
+package imports
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// manifests parse the manifests by file name.
+var manifests = map[string]func([]byte) []Dependency{
+	"go.mod":           goMod,
+	"Gopkg.toml":       gopkgToml,
+	"package.json":     packageJSON,
+	"requirements.txt": requirementsTxt,
+	"pom.xml":          pomXML,
+}
+
+// IsManifest reports whether the file at the path declares dependencies:
+// go.mod, Gopkg.toml, package.json, requirements.txt or pom.xml.
+func IsManifest(filename string) bool {
+	_, ok := manifests[path.Base(filename)]
+	return ok
+}
+
+// FromManifest returns the dependencies declared in the manifest, and
+// false if the file is not a manifest. Manifests which cannot be parsed
+// have no dependencies.
+func FromManifest(filename string, content []byte) ([]Dependency, bool) {
+	parse, ok := manifests[path.Base(filename)]
+	if !ok {
+		return nil, false
+	}
+	return parse(content), true
+}
+
+func goMod(content []byte) []Dependency {
+	var (
+		deps    []Dependency
+		inBlock bool
+	)
+	for _, line := range strings.Split(string(content), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+
+		switch {
+		case len(fields) == 0:
+			continue
+		case inBlock && fields[0] == ")":
+			inBlock = false
+			continue
+		case fields[0] == "require" && len(fields) > 1 && fields[1] == "(":
+			inBlock = true
+			continue
+		case fields[0] == "require":
+			fields = fields[1:]
+		case !inBlock:
+			continue
+		}
+
+		if len(fields) >= 2 {
+			deps = append(deps, Dependency{Ecosystem: Go, Name: fields[0], Version: fields[1]})
+		}
+	}
+	return deps
+}
+
+var tomlValue = regexp.MustCompile(`^\s*(\w+)\s*=\s*"([^"]*)"`)
+
+// gopkgToml reads the constraints and overrides of dep manifests, the
+// version being the version, branch or revision required.
+func gopkgToml(content []byte) []Dependency {
+	var (
+		deps []Dependency
+		dep  *Dependency
+	)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			dep = nil
+			if line == "[[constraint]]" || line == "[[override]]" {
+				deps = append(deps, Dependency{Ecosystem: Go})
+				dep = &deps[len(deps)-1]
+			}
+			continue
+		}
+
+		m := tomlValue.FindStringSubmatch(line)
+		if dep == nil || m == nil {
+			continue
+		}
+		switch m[1] {
+		case "name":
+			dep.Name = m[2]
+		case "version", "branch", "revision":
+			dep.Version = m[2]
+		}
+	}
+
+	// constraints must be named, skip the ones which are not
+	named := deps[:0]
+	for _, d := range deps {
+		if d.Name != "" {
+			named = append(named, d)
+		}
+	}
+	return named
+}
+
+func packageJSON(content []byte) []Dependency {
+	var pkg map[string]json.RawMessage
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return nil
+	}
+
+	var deps []Dependency
+	for _, field := range []string{"dependencies", "devDependencies", "peerDependencies", "optionalDependencies"} {
+		var versions map[string]string
+		if err := json.Unmarshal(pkg[field], &versions); err != nil {
+			continue
+		}
+
+		names := make([]string, 0, len(versions))
+		for name := range versions {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			deps = append(deps, Dependency{Ecosystem: JavaScript, Name: name, Version: versions[name]})
+		}
+	}
+	return deps
+}
+
+var requirement = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*([^;#]*)`)
+
+// requirementsTxt reads the requirements of pip, skipping options like
+// -r other.txt and editable installs.
+func requirementsTxt(content []byte) []Dependency {
+	var deps []Dependency
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		m := requirement.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		deps = append(deps, Dependency{
+			Ecosystem: Python,
+			Name:      m[1],
+			Version:   strings.Replace(strings.TrimSpace(m[2]), " ", "", -1),
+		})
+	}
+	return deps
+}
+
+type pomDependency struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+}
+
+// pomXML reads the dependencies of Maven projects, including the managed
+// ones. Versions may be properties, like ${project.version}.
+func pomXML(content []byte) []Dependency {
+	var pom struct {
+		Dependencies []pomDependency `xml:"dependencies>dependency"`
+		Managed      []pomDependency `xml:"dependencyManagement>dependencies>dependency"`
+	}
+	if err := xml.Unmarshal(content, &pom); err != nil {
+		return nil
+	}
+
+	var deps []Dependency
+	for _, d := range append(pom.Dependencies, pom.Managed...) {
+		deps = append(deps, Dependency{
+			Ecosystem: Java,
+			Name:      d.GroupID + ":" + d.ArtifactID,
+			Version:   strings.TrimSpace(d.Version),
+		})
+	}
+	return deps
+}
@@ -0,0 +1,73 @@
+package imports
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromManifest(t *testing.T) {
+	cases := []struct {
+		filename, content string
+		expected          []Dependency
+	}{
+		{
+			"go.mod",
+			"module example.com/a\n\ngo 1.12\n\nrequire github.com/pkg/errors v0.8.1\n\nrequire (\n\tgopkg.in/src-d/go-git.v4 v4.13.1 // indirect\n\t// github.com/commented v1.0.0\n\tgolang.org/x/text v0.3.2\n)\n\nreplace golang.org/x/text => ../text\n",
+			[]Dependency{
+				{Go, "github.com/pkg/errors", "v0.8.1"},
+				{Go, "gopkg.in/src-d/go-git.v4", "v4.13.1"},
+				{Go, "golang.org/x/text", "v0.3.2"},
+			},
+		},
+		{
+			"vendor/a/Gopkg.toml",
+			"required = [\"x\"]\n\n[[constraint]]\n  name = \"github.com/pkg/errors\"\n  version = \"0.8.0\"\n\n[[override]]\n  name = \"gopkg.in/yaml.v2\"\n  branch = \"v2\"\n\n[[constraint]]\n  version = \"1.0.0\"\n\n[prune]\n  name = \"ignored\"\n",
+			[]Dependency{{Go, "github.com/pkg/errors", "0.8.0"}, {Go, "gopkg.in/yaml.v2", "v2"}},
+		},
+		{
+			"package.json",
+			`{"name": "a", "dependencies": {"react": "^16.0.0", "lodash": "4.17.11"}, "devDependencies": {"jest": "*"}}`,
+			[]Dependency{{JavaScript, "lodash", "4.17.11"}, {JavaScript, "react", "^16.0.0"}, {JavaScript, "jest", "*"}},
+		},
+		{"package.json", "not json", nil},
+		{
+			"requirements.txt",
+			"# comment\nflask==1.0.2\nrequests[security] >= 2.8.1, < 3 ; python_version < '3'\n-r other.txt\n-e git+https://example.com/a.git\nsix\n",
+			[]Dependency{{Python, "flask", "==1.0.2"}, {Python, "requests", ">=2.8.1,<3"}, {Python, "six", ""}},
+		},
+		{
+			"pom.xml",
+			"<project><dependencies><dependency><groupId>junit</groupId><artifactId>junit</artifactId><version> 4.12 </version></dependency></dependencies>" +
+				"<dependencyManagement><dependencies><dependency><groupId>org.a</groupId><artifactId>b</artifactId><version>${project.version}</version></dependency></dependencies></dependencyManagement></project>",
+			[]Dependency{{Java, "junit:junit", "4.12"}, {Java, "org.a:b", "${project.version}"}},
+		},
+	}
+
+	for _, c := range cases {
+		got, ok := FromManifest(c.filename, []byte(c.content))
+		if !ok {
+			t.Errorf("expected %s to be a manifest", c.filename)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("expected the dependencies %v of %s\n%s\ngot %v", c.expected, c.filename, c.content, got)
+		}
+	}
+}
+
+func TestIsManifest(t *testing.T) {
+	cases := map[string]bool{
+		"go.mod":               true,
+		"a/b/requirements.txt": true,
+		"pom.xml":              true,
+		"go.sum":               false,
+		"package-lock.json":    false,
+		"main.go":              false,
+	}
+
+	for filename, expected := range cases {
+		if got := IsManifest(filename); got != expected {
+			t.Errorf("expected IsManifest(%q) to be %v, got %v", filename, expected, got)
+		}
+	}
+}
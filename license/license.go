@@ -0,0 +1,236 @@
+// Package license detects the licenses of files, by comparing them with the
+// texts of the most used licenses and the notices recommended for the top
+// of source files, and reading SPDX-License-Identifier tags.
+package license
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+const (
+	// MinConfidence is the similarity below which texts are not reported
+	// as licenses.
+	MinConfidence = 0.75
+
+	// headerSize is how much of the start of source files is looked for
+	// notices.
+	headerSize = 16 << 10
+)
+
+// Match is a license detected.
+type Match struct {
+	// ID is the SPDX identifier of the license, like Apache-2.0.
+	ID string
+	// Confidence is how similar the text is to the license, from
+	// MinConfidence to 1.
+	Confidence float64
+}
+
+var licenseFileNames = map[string]bool{
+	"license":   true,
+	"licence":   true,
+	"copying":   true,
+	"copyright": true,
+	"unlicense": true,
+}
+
+// IsLicenseFile reports whether the file at the path is meant to hold the
+// license of the project, like LICENSE, COPYING.txt, LICENSE-MIT or
+// LICENSE.APACHE.
+func IsLicenseFile(filename string) bool {
+	name := strings.ToLower(path.Base(filename))
+	ext := path.Ext(name)
+	switch ext {
+	case "", ".txt", ".md", ".markdown", ".rst":
+	default:
+		// like LICENSE.APACHE, but not license.go
+		if _, ok := enry.GetLanguageByExtension(name); ok {
+			return false
+		}
+	}
+	name = strings.TrimSuffix(name, ext)
+
+	if i := strings.IndexAny(name, "-_"); i > 0 {
+		name = name[:i]
+	}
+	return licenseFileNames[name]
+}
+
+// DetectFile detects the license of a file, by its whole text if it is a
+// license file, see IsLicenseFile, or by its header otherwise.
+func DetectFile(filename string, content []byte) (Match, bool) {
+	if IsLicenseFile(filename) {
+		if m, ok := Detect(content); ok {
+			return m, true
+		}
+	}
+	return DetectHeader(content)
+}
+
+// Detect returns the license with the text most similar to the content.
+func Detect(content []byte) (Match, bool) {
+	c := loadCorpus()
+	words := bigrams(normalize(content))
+	return best(c.texts, func(license map[string]bool) float64 {
+		return dice(words, license)
+	})
+}
+
+var spdxTag = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+-]+)`)
+
+// DetectHeader returns the license of a source file named by an
+// SPDX-License-Identifier tag, or the one with the notice found at the top
+// of the file.
+func DetectHeader(content []byte) (Match, bool) {
+	if len(content) > headerSize {
+		content = content[:headerSize]
+	}
+	if m := spdxTag.FindSubmatch(content); m != nil {
+		return Match{ID: currentID(string(m[1])), Confidence: 1}, true
+	}
+
+	c := loadCorpus()
+	words := bigrams(normalize(content))
+	return best(c.notices, func(notice map[string]bool) float64 {
+		return containment(notice, words)
+	})
+}
+
+// deprecatedGNU are the GNU licenses whose SPDX identifiers are deprecated
+// in favor of the -only and -or-later ones, like GPL-2.0 and GPL-2.0+.
+var deprecatedGNU = map[string]bool{
+	"GPL-1.0":  true,
+	"GPL-2.0":  true,
+	"GPL-3.0":  true,
+	"LGPL-2.0": true,
+	"LGPL-2.1": true,
+	"LGPL-3.0": true,
+	"AGPL-1.0": true,
+	"AGPL-3.0": true,
+}
+
+// currentID returns the current SPDX identifier of a deprecated one.
+func currentID(id string) string {
+	switch {
+	case deprecatedGNU[id]:
+		return id + "-only"
+	case strings.HasSuffix(id, "+") && deprecatedGNU[strings.TrimSuffix(id, "+")]:
+		return strings.TrimSuffix(id, "+") + "-or-later"
+	}
+	return id
+}
+
+// best returns the license with the highest similarity, the one with the
+// longest text on ties, so BSD-3-Clause is chosen over BSD-2-Clause when
+// both match.
+func best(licenses map[string]map[string]bool, similarity func(map[string]bool) float64) (Match, bool) {
+	var (
+		match Match
+		size  int
+	)
+	for id, license := range licenses {
+		s := similarity(license)
+		if s > match.Confidence || (s == match.Confidence && len(license) > size) {
+			match, size = Match{ID: id, Confidence: s}, len(license)
+		}
+	}
+	return match, match.Confidence >= MinConfidence
+}
+
+type corpus struct {
+	texts   map[string]map[string]bool
+	notices map[string]map[string]bool
+}
+
+var (
+	corpusOnce sync.Once
+	theCorpus  *corpus
+)
+
+// loadCorpus returns the bigrams of the texts and notices of the licenses,
+// computed the first time they are needed.
+func loadCorpus() *corpus {
+	corpusOnce.Do(func() {
+		theCorpus = &corpus{
+			texts:   make(map[string]map[string]bool, len(texts)),
+			notices: make(map[string]map[string]bool, len(texts)+len(notices)),
+		}
+		for id, text := range texts {
+			theCorpus.texts[id] = bigrams(normalize([]byte(text)))
+			// short licenses are copied whole at the top of files
+			if len(text) < 2<<10 {
+				theCorpus.notices[id] = theCorpus.texts[id]
+			}
+		}
+		for id, notice := range notices {
+			theCorpus.notices[id] = bigrams(normalize([]byte(notice)))
+		}
+	})
+	return theCorpus
+}
+
+// normalize returns the lower cased words of the text, leaving out
+// punctuation and copyright lines, which differ between projects using
+// the same license.
+func normalize(text []byte) []string {
+	var words []string
+	for _, line := range bytes.Split(text, []byte("\n")) {
+		line = bytes.ToLower(line)
+		trimmed := bytes.TrimLeft(line, " \t/*#;-!%")
+		if bytes.HasPrefix(trimmed, []byte("copyright")) || bytes.Contains(line, []byte("(c)")) {
+			continue
+		}
+
+		words = append(words, strings.FieldsFunc(string(line), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+	return words
+}
+
+// bigrams returns the pairs of consecutive words, which tell apart texts
+// using the same words, like versions of the same license.
+func bigrams(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for i := 1; i < len(words); i++ {
+		set[words[i-1]+" "+words[i]] = true
+	}
+	return set
+}
+
+// dice returns the Sørensen–Dice coefficient of the sets, how similar they
+// are from 0 to 1.
+func dice(a, b map[string]bool) float64 {
+	if len(a)+len(b) == 0 {
+		return 0
+	}
+	return 2 * float64(intersection(a, b)) / float64(len(a)+len(b))
+}
+
+// containment returns how much of a is found in b, from 0 to 1.
+func containment(a, b map[string]bool) float64 {
+	if len(a) == 0 {
+		return 0
+	}
+	return float64(intersection(a, b)) / float64(len(a))
+}
+
+func intersection(a, b map[string]bool) int {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	n := 0
+	for k := range a {
+		if b[k] {
+			n++
+		}
+	}
+	return n
+}
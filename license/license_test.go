@@ -0,0 +1,35 @@
+package license
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectGNUTexts(t *testing.T) {
+	for _, id := range []string{"GPL-2.0-only", "GPL-3.0-only", "AGPL-3.0-only", "LGPL-2.1-only", "LGPL-3.0-only"} {
+		m, ok := Detect([]byte(texts[id]))
+		if !ok || m.ID != id {
+			t.Errorf("expected the text of %s to be detected as such, got %v", id, m)
+		}
+	}
+}
+
+func TestDetectHeader(t *testing.T) {
+	cases := []struct {
+		header, id string
+	}{
+		{"// SPDX-License-Identifier: MIT\n", "MIT"},
+		{"// SPDX-License-Identifier: GPL-2.0\n", "GPL-2.0-only"},
+		{"// SPDX-License-Identifier: GPL-2.0+\n", "GPL-2.0-or-later"},
+		{"// SPDX-License-Identifier: LGPL-2.1-or-later\n", "LGPL-2.1-or-later"},
+		{"# " + strings.Replace(notices["AGPL-3.0-or-later"], "\n", "\n# ", -1), "AGPL-3.0-or-later"},
+		{"# " + strings.Replace(notices["GPL-3.0-or-later"], "\n", "\n# ", -1), "GPL-3.0-or-later"},
+	}
+
+	for _, c := range cases {
+		m, ok := DetectHeader([]byte(c.header))
+		if !ok || m.ID != c.id {
+			t.Errorf("expected %s in %q, got %v", c.id, c.header, m)
+		}
+	}
+}
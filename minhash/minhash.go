@@ -0,0 +1,166 @@
+// Package minhash computes MinHash signatures of files, which estimate how
+// similar their tokens are, and the bands to find the similar ones with
+// locality sensitive hashing (LSH) without comparing every pair of files.
+package minhash
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// Size is the number of hashes of the signatures.
+	Size = 128
+	// Bands is the number of bands the signatures are split in by Bands.
+	// With rows of 8 hashes, files are likely to share a band when more
+	// than about 70% of their shingles are the same.
+	Bands = 16
+	// Shingle is the number of consecutive tokens hashed together, so
+	// files with the same tokens in other order are told apart.
+	Shingle = 3
+)
+
+// seeds are the seeds of the Size hash functions, fixed so signatures are
+// comparable across processes.
+var seeds = func() [Size]uint64 {
+	var s [Size]uint64
+	x := uint64(0x2545f4914f6cdd1d)
+	for i := range s {
+		x = mix(x + uint64(i))
+		s[i] = x
+	}
+	return s
+}()
+
+// Signature is the MinHash signature of a file.
+type Signature []uint64
+
+// New returns the signature of the content, or nil if it has less than
+// Shingle tokens.
+func New(content []byte) Signature {
+	tokens := Tokens(content)
+	if len(tokens) < Shingle {
+		return nil
+	}
+
+	sig := make(Signature, Size)
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+
+	h := fnv.New64a()
+	for i := 0; i+Shingle <= len(tokens); i++ {
+		h.Reset()
+		for _, t := range tokens[i : i+Shingle] {
+			h.Write(t)
+			h.Write([]byte{0})
+		}
+		shingle := h.Sum64()
+
+		for j, seed := range seeds {
+			if v := mix(shingle ^ seed); v < sig[j] {
+				sig[j] = v
+			}
+		}
+	}
+	return sig
+}
+
+// Tokens splits the content in identifiers, numbers and single punctuation
+// characters, leaving out white space so formatting changes do not count.
+func Tokens(content []byte) [][]byte {
+	var tokens [][]byte
+	for i := 0; i < len(content); {
+		r, size := utf8.DecodeRune(content[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case isWord(r):
+			j := i + size
+			for j < len(content) {
+				r, size := utf8.DecodeRune(content[j:])
+				if !isWord(r) {
+					break
+				}
+				j += size
+			}
+			tokens = append(tokens, content[i:j])
+			i = j
+		default:
+			tokens = append(tokens, content[i:i+size])
+			i += size
+		}
+	}
+	return tokens
+}
+
+func isWord(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Similarity estimates the Jaccard similarity of the shingles of the files
+// of the signatures, from 0 to 1.
+func Similarity(a, b Signature) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var same int
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// Bands returns the hashes of the Bands bands of the signature. Files
+// sharing any of them are candidates to be similar.
+func (s Signature) Bands() []uint64 {
+	rows := len(s) / Bands
+	bands := make([]uint64, Bands)
+	for b := range bands {
+		h := mix(uint64(b))
+		for _, v := range s[b*rows : (b+1)*rows] {
+			h = mix(h ^ v)
+		}
+		bands[b] = h
+	}
+	return bands
+}
+
+// Bytes encodes the signature, to keep it in rows.
+func (s Signature) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+
+	b := make([]byte, 8*len(s))
+	for i, v := range s {
+		binary.BigEndian.PutUint64(b[8*i:], v)
+	}
+	return b
+}
+
+// FromBytes decodes a signature encoded by Signature.Bytes.
+func FromBytes(b []byte) Signature {
+	if len(b) == 0 {
+		return nil
+	}
+
+	s := make(Signature, len(b)/8)
+	for i := range s {
+		s[i] = binary.BigEndian.Uint64(b[8*i:])
+	}
+	return s
+}
+
+// mix is the finalizer of splitmix64, which spreads the bits of x.
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
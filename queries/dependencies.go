@@ -0,0 +1,49 @@
+package queries
+
+import (
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	"github.com/eiso/go-engine/dataset"
+	"github.com/eiso/go-engine/imports"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+var (
+	extractDependencies = dataset.RegisterMapper(
+		[]string{"repositoryID", "path", "content", "isBinary"},
+		[]string{"repositoryID", "ecosystem", "dependency"},
+		func(row dataset.Row) error {
+			path, content := row.String("path"), row.Bytes("content")
+			if row.Bool("isBinary") || enry.IsVendor(path) {
+				return nil
+			}
+
+			for _, d := range imports.Extract(path, enry.GetLanguage(path, content), content) {
+				if err := gio.Emit(row.Get("repositoryID"), d.Ecosystem, d.Name); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	countDependencyFiles = countGroups("repositoryID", "ecosystem", "dependency")
+)
+
+// Dependencies finds the packages every repository depends on, imported
+// by its source files or declared in its manifests, in the commits the
+// references p.Refs point to, HEAD if there are none, counting the files
+// importing or declaring them. They are the edges of the dependency graph
+// of the repositories. Vendored files are left out. p.Limit applies to the
+// whole result.
+func Dependencies(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	blobs, err := headBlobs(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return top(blobs.
+		Map("extract dependencies", extractDependencies).
+		GroupBy("group by dependency", "repositoryID", "ecosystem", "dependency").
+		Map("count files", countDependencyFiles),
+		"sort dependencies", p.Limit, dataset.Asc("repositoryID"), dataset.Desc("count")), nil
+}
@@ -0,0 +1,219 @@
+package queries
+
+import (
+	"log"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	"github.com/eiso/go-engine/dataset"
+	"github.com/eiso/go-engine/minhash"
+	"github.com/eiso/go-engine/udf"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+// MinSimilarity is the estimated similarity from which files are near
+// duplicates.
+const MinSimilarity = 0.8
+
+// MaxBandFiles is the number of files sharing a band that are compared with
+// each other. Bands shared by more files, like those of boilerplate or very
+// short files, would take quadratic time to compare, so only their first
+// MaxBandFiles files are, and near duplicates of the rest are found by
+// their other bands.
+const MaxBandFiles = 200
+
+var (
+	signatures = dataset.RegisterMapper(
+		[]string{"repositoryID", "blobHash", "path", "content", "isBinary"},
+		[]string{"repositoryID", "blobHash", "path", "signature"},
+		func(row dataset.Row) error {
+			if row.Bool("isBinary") {
+				return nil
+			}
+
+			sig := minhash.New(row.Bytes("content"))
+			if sig == nil {
+				return nil
+			}
+			return gio.Emit(row.Get("repositoryID"), row.Get("blobHash"), row.Get("path"), sig.Bytes())
+		})
+
+	// firstSignatures keeps one of the repositories and paths of every
+	// blob.
+	firstSignatures = dataset.RegisterMapper(
+		[]string{"blobHash"},
+		[]string{"blobHash", "repositoryID", "path", "signature"},
+		func(row dataset.Row) error {
+			first := row.Group()[0]
+			return gio.Emit(row.Get("blobHash"), first[0], first[1], first[2])
+		})
+
+	bands = dataset.RegisterMapper(
+		[]string{"blobHash", "repositoryID", "path", "signature"},
+		[]string{"band", "blobHash", "repositoryID", "path", "signature"},
+		func(row dataset.Row) error {
+			for _, band := range minhash.FromBytes(row.Bytes("signature")).Bands() {
+				err := gio.Emit(int64(band), row.Get("blobHash"), row.Get("repositoryID"),
+					row.Get("path"), row.Get("signature"))
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	// candidatePairs compares the files sharing a band, up to
+	// MaxBandFiles, emitting the pairs similar enough with the lowest blob
+	// hash first.
+	candidatePairs = dataset.RegisterMapper(
+		[]string{"band"},
+		DuplicatesColumns,
+		func(row dataset.Row) error {
+			files := row.Group()
+			if len(files) > MaxBandFiles {
+				log.Printf("comparing %d of the %d files sharing band %d", MaxBandFiles, len(files), row.Int64("band"))
+				files = files[:MaxBandFiles]
+			}
+			sigs := make([]minhash.Signature, len(files))
+			for i, file := range files {
+				sigs[i] = minhash.FromBytes(gio.ToBytes(file[3]))
+			}
+
+			for i := range files {
+				for j := i + 1; j < len(files); j++ {
+					similarity := minhash.Similarity(sigs[i], sigs[j])
+					if similarity < MinSimilarity {
+						continue
+					}
+
+					a, b := files[i], files[j]
+					if gio.ToString(b[0]) < gio.ToString(a[0]) {
+						a, b = b, a
+					}
+					err := gio.Emit(a[0], a[1], a[2], b[0], b[1], b[2], similarity)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+
+	normalizedHashes             = normalizedHash(true)
+	normalizedHashesWithComments = normalizedHash(false)
+
+	// countCopies counts the files, blobs and repositories with the same
+	// normalized content, keeping the ones with several blobs.
+	countCopies = dataset.RegisterMapper(
+		[]string{"normalizedHash"},
+		[]string{"normalizedHash", "files", "blobs", "repositories"},
+		func(row dataset.Row) error {
+			blobs := make(map[string]bool)
+			repositories := make(map[string]bool)
+			files := row.Group()
+			for _, file := range files {
+				repositories[gio.ToString(file[0])] = true
+				blobs[gio.ToString(file[1])] = true
+			}
+			if len(blobs) < 2 {
+				return nil
+			}
+
+			return gio.Emit(row.Get("normalizedHash"), int64(len(files)),
+				int64(len(blobs)), int64(len(repositories)))
+		})
+
+	distinctPairs = dataset.RegisterMapper(
+		[]string{"blobHash", "otherBlobHash"},
+		DuplicatesColumns,
+		func(row dataset.Row) error {
+			first := row.Group()[0]
+			return gio.Emit(row.Get("blobHash"), first[0], first[1],
+				row.Get("otherBlobHash"), first[2], first[3], first[4])
+		})
+)
+
+// normalizedHash registers a mapper hashing the content of the files with
+// udf.HashContent, without their comments if dropComments.
+func normalizedHash(dropComments bool) dataset.Mapper {
+	return dataset.RegisterMapper(
+		[]string{"repositoryID", "blobHash", "path", "content"},
+		[]string{"normalizedHash", "repositoryID", "blobHash"},
+		func(row dataset.Row) error {
+			var (
+				lang    string
+				opts    []udf.HashOption
+				content = row.Bytes("content")
+			)
+			if dropComments {
+				lang = enry.GetLanguage(row.String("path"), content)
+				opts = append(opts, udf.WithoutComments())
+			}
+			hash := udf.HashContent(lang, content, opts...)
+			return gio.Emit(hash, row.Get("repositoryID"), row.Get("blobHash"))
+		})
+}
+
+// DuplicatesColumns are the columns of the pairs of near duplicates found
+// by NearDuplicates.
+var DuplicatesColumns = []string{
+	"blobHash", "repositoryID", "path",
+	"otherBlobHash", "otherRepositoryID", "otherPath",
+	"similarity",
+}
+
+// NearDuplicates finds the pairs of files of d, with the columns blobHash,
+// repositoryID, path and signature, that have an estimated similarity of
+// at least MinSimilarity, see DuplicatesColumns. Only the files sharing a
+// band of their signatures are compared, see minhash.Signature.Bands, and
+// every blob once, so the same content in several repositories or paths
+// is reported with one of them.
+func NearDuplicates(d *dataset.Dataset) *dataset.Dataset {
+	return d.
+		Select("signatures", "blobHash", "repositoryID", "path", "signature").
+		GroupBy("group by blob", "blobHash").
+		Map("distinct blobs", firstSignatures).
+		Map("bands", bands).
+		GroupBy("group by band", "band").
+		Map("compare files", candidatePairs).
+		GroupBy("group by pair", "blobHash", "otherBlobHash").
+		Map("distinct pairs", distinctPairs)
+}
+
+// NearDuplicateFiles finds the files of the commits the references p.Refs
+// point to, HEAD if there are none, which are near duplicates of others in
+// the same or other repositories, see NearDuplicates, keeping the p.Limit
+// most similar pairs.
+func NearDuplicateFiles(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	blobs, err := headBlobs(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return top(NearDuplicates(blobs.Map("signatures", signatures)),
+		"top duplicates", p.Limit, dataset.Desc("similarity")), nil
+}
+
+// SameContentFiles finds the files of the commits the references p.Refs
+// point to, HEAD if there are none, with the same content once white space
+// at the end of lines, line endings and comments, unless p.KeepComments, are
+// left out, see udf.HashContent, but different blobs. It counts the
+// files, blobs and repositories of every normalized content, keeping the
+// p.Limit ones with more blobs.
+func SameContentFiles(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	blobs, err := headBlobs(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := normalizedHashes
+	if p.KeepComments {
+		hashes = normalizedHashesWithComments
+	}
+
+	return top(blobs.
+		Map("normalized hashes", hashes).
+		GroupBy("group by normalized hash", "normalizedHash").
+		Map("count copies", countCopies),
+		"top copies", p.Limit, dataset.Desc("blobs")), nil
+}
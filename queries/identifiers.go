@@ -0,0 +1,72 @@
+package queries
+
+import (
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	"github.com/eiso/go-engine/dataset"
+	"github.com/eiso/go-engine/udf"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+var (
+	// fileTokens counts the tokens of the identifiers of every file
+	// written as part of the project.
+	fileTokens = dataset.RegisterMapper(
+		[]string{"path", "content", "isBinary"},
+		[]string{"token", "count"},
+		func(row dataset.Row) error {
+			path, content := row.String("path"), row.Bytes("content")
+			if row.Bool("isBinary") || enry.IsVendor(path) || udf.IsGenerated(path, content) {
+				return nil
+			}
+
+			lang := enry.GetLanguage(path, content)
+			if enry.GetLanguageType(lang) != enry.Programming {
+				return nil
+			}
+
+			counts := make(map[string]int64)
+			for _, id := range udf.Identifiers(lang, content) {
+				for _, t := range udf.SplitIdentifier(id) {
+					counts[t]++
+				}
+			}
+			for t, n := range counts {
+				if err := gio.Emit(t, n); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	// sumTokens sums the counts of a token and the files it is in.
+	sumTokens = dataset.RegisterMapper(
+		[]string{"token"},
+		[]string{"token", "count", "files"},
+		func(row dataset.Row) error {
+			var count int64
+			files := row.Group()
+			for _, file := range files {
+				count += gio.ToInt64(file[0])
+			}
+			return gio.Emit(row.Get("token"), count, int64(len(files)))
+		})
+)
+
+// TokenVocabulary counts the tokens of the identifiers, see
+// udf.ExtractTokens, of the programming language files in the commits the
+// references p.Refs point to, HEAD if there are none, and the files they
+// are in, keeping the p.Limit most used ones. Vendored and generated files
+// are left out.
+func TokenVocabulary(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	blobs, err := headBlobs(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return top(blobs.
+		Map("file tokens", fileTokens).
+		GroupBy("group by token", "token").
+		Map("sum tokens", sumTokens),
+		"top tokens", p.Limit, dataset.Desc("count")), nil
+}
@@ -0,0 +1,163 @@
+package queries
+
+import (
+	"math"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	engine "github.com/eiso/go-engine"
+	"github.com/eiso/go-engine/dataset"
+	"github.com/eiso/go-engine/udf"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+var (
+	classifyLanguages = dataset.RegisterMapper(
+		[]string{"repositoryID", "path", "content", "isBinary"},
+		[]string{"repositoryID", "lang"},
+		func(row dataset.Row) error {
+			if row.Bool("isBinary") {
+				return nil
+			}
+
+			lang := enry.GetLanguage(row.String("path"), row.Bytes("content"))
+			if lang == "" {
+				return nil
+			}
+
+			return gio.Emit(row.Get("repositoryID"), lang)
+		})
+
+	// classifyLanguageBytes keeps the files counted by GitHub in the
+	// language bar of repositories, see linguistLanguage.
+	classifyLanguageBytes = dataset.RegisterMapper(
+		[]string{"repositoryID", "path", "content", "isBinary", "blobSize"},
+		[]string{"repositoryID", "lang", "bytes"},
+		func(row dataset.Row) error {
+			lang := linguistLanguage(row.String("path"), row.Bytes("content"), row.Bool("isBinary"))
+			if lang == "" {
+				return nil
+			}
+			return gio.Emit(row.Get("repositoryID"), lang, row.Int64("blobSize"))
+		})
+
+	// languagePercentages sums the bytes of every language of a repository
+	// and their share of the bytes of all of them.
+	languagePercentages = dataset.RegisterMapper(
+		[]string{"repositoryID"},
+		[]string{"repositoryID", "lang", "bytes", "percentage"},
+		func(row dataset.Row) error {
+			var (
+				langs []string
+				bytes = make(map[string]int64)
+				total int64
+			)
+			for _, file := range row.Group() {
+				lang, size := gio.ToString(file[0]), gio.ToInt64(file[1])
+				if _, ok := bytes[lang]; !ok {
+					langs = append(langs, lang)
+				}
+				bytes[lang] += size
+				total += size
+			}
+
+			for _, lang := range langs {
+				var percentage float64
+				if total > 0 {
+					percentage = math.Round(float64(bytes[lang])*10000/float64(total)) / 100
+				}
+				if err := gio.Emit(row.Get("repositoryID"), lang, bytes[lang], percentage); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	countLanguages           = countGroups("lang")
+	countRepositoryLanguages = countGroups("repositoryID", "lang")
+)
+
+// linguistLanguage returns the language of the file if it is counted by
+// GitHub in the language bar of repositories: neither binary, vendored,
+// generated, documentation nor configuration files, and only programming
+// and markup languages.
+func linguistLanguage(path string, content []byte, isBinary bool) string {
+	if isBinary ||
+		enry.IsVendor(path) ||
+		enry.IsDocumentation(path) ||
+		enry.IsConfiguration(path) ||
+		udf.IsGenerated(path, content) {
+		return ""
+	}
+
+	lang := enry.GetLanguage(path, content)
+	switch enry.GetLanguageType(lang) {
+	case enry.Programming, enry.Markup:
+		return lang
+	}
+	return ""
+}
+
+// languages classifies the language of the files of the commits the
+// references p.Refs point to.
+func languages(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	blobs, err := dataset.Read(f, engine.Repositories(p.Path, p.Partitions).
+		References().Filter(p.Refs...).
+		Commits().
+		Trees().
+		Blobs())
+	if err != nil {
+		return nil, err
+	}
+
+	return blobs.Map("classify languages", classifyLanguages), nil
+}
+
+// MostUsedLanguages counts the files of every language in the commits the
+// references p.Refs point to, keeping the p.Limit most used ones.
+func MostUsedLanguages(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	langs, err := languages(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return top(langs.
+		Select("languages", "lang").
+		GroupBy("group by lang", "lang").
+		Map("count languages", countLanguages),
+		"top languages", p.Limit, dataset.Desc("count")), nil
+}
+
+// LanguagesPerRepository counts the files of every language of every
+// repository in the commits the references p.Refs point to, sorted by
+// repository and count. p.Limit applies to the whole result.
+func LanguagesPerRepository(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	langs, err := languages(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return top(langs.
+		GroupBy("group by repository and lang", "repositoryID", "lang").
+		Map("count languages", countRepositoryLanguages),
+		"sort languages", p.Limit, dataset.Asc("repositoryID"), dataset.Desc("count")), nil
+}
+
+// LanguageBreakdown computes the bytes of every language of every
+// repository in the commits the references p.Refs point to, HEAD if there
+// are none, and their percentage of the bytes of the repository, like the
+// language bar of GitHub. Vendored, generated, documentation and
+// configuration files are not counted, nor languages other than
+// programming and markup ones. p.Limit applies to the whole result.
+func LanguageBreakdown(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	blobs, err := headBlobs(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return top(blobs.
+		Map("classify language bytes", classifyLanguageBytes).
+		GroupBy("group by repository", "repositoryID").
+		Map("language percentages", languagePercentages),
+		"sort languages", p.Limit, dataset.Asc("repositoryID"), dataset.Desc("bytes")), nil
+}
@@ -0,0 +1,61 @@
+package queries
+
+import (
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	"github.com/eiso/go-engine/dataset"
+	"github.com/eiso/go-engine/license"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+var (
+	detectLicenses = dataset.RegisterMapper(
+		[]string{"repositoryID", "path", "content", "isBinary"},
+		[]string{"repositoryID", "license", "confidence"},
+		func(row dataset.Row) error {
+			path := row.String("path")
+			if row.Bool("isBinary") || enry.IsVendor(path) {
+				return nil
+			}
+
+			m, ok := license.DetectFile(path, row.Bytes("content"))
+			if !ok {
+				return nil
+			}
+			return gio.Emit(row.Get("repositoryID"), m.ID, m.Confidence)
+		})
+
+	// summarizeLicenses counts the files of every license of a repository,
+	// with the highest confidence of their detections.
+	summarizeLicenses = dataset.RegisterMapper(
+		[]string{"repositoryID", "license"},
+		[]string{"repositoryID", "license", "files", "confidence"},
+		func(row dataset.Row) error {
+			var confidence float64
+			files := row.Group()
+			for _, file := range files {
+				if c := gio.ToFloat64(file[0]); c > confidence {
+					confidence = c
+				}
+			}
+			return gio.Emit(row.Get("repositoryID"), row.Get("license"), int64(len(files)), confidence)
+		})
+)
+
+// LicensesPerRepository detects the licenses of every repository in the
+// commits the references p.Refs point to, HEAD if there are none, from its
+// license files and the license notices of its source files, counting the
+// files of every license. Vendored files are left out. p.Limit applies to
+// the whole result.
+func LicensesPerRepository(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	blobs, err := headBlobs(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return top(blobs.
+		Map("detect licenses", detectLicenses).
+		GroupBy("group by repository and license", "repositoryID", "license").
+		Map("summarize licenses", summarizeLicenses),
+		"sort licenses", p.Limit, dataset.Asc("repositoryID"), dataset.Desc("files")), nil
+}
@@ -0,0 +1,134 @@
+// Package queries implements common analyses of repositories, like the
+// queries of source{d} QuerySetApp, as named functions building Gleam
+// flows.
+package queries
+
+import (
+	"sort"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	engine "github.com/eiso/go-engine"
+	"github.com/eiso/go-engine/dataset"
+	"github.com/pkg/errors"
+)
+
+// Params are the parameters of the queries. Each query documents the ones
+// it uses.
+type Params struct {
+	// Path is where the repositories are.
+	Path string
+	// Partitions is the number of partitions to read the repositories with.
+	Partitions int
+	// Refs are the names of the references to read, all of them if empty.
+	Refs []string
+	// Limit is the maximum number of rows of the result, no limit if zero.
+	Limit int
+	// KeepComments makes the queries comparing the content of files, like
+	// SameContentFiles, tell apart those only differing in their comments.
+	KeepComments bool
+}
+
+// Query builds the flow computing a query.
+type Query func(f *flow.Flow, p Params) (*dataset.Dataset, error)
+
+var registry = map[string]Query{}
+
+// Register makes a query available by name.
+func Register(name string, q Query) {
+	if _, ok := registry[name]; ok {
+		panic("query " + name + " is already registered")
+	}
+	registry[name] = q
+}
+
+// Get returns the query registered with the given name.
+func Get(name string) (Query, error) {
+	q, ok := registry[name]
+	if !ok {
+		return nil, errors.Errorf("query %s is not implemented", name)
+	}
+	return q, nil
+}
+
+// Names returns the names of the registered queries, sorted.
+func Names() []string {
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("repositories", Repositories)
+	Register("references", References)
+	Register("referencesMaster", master(References))
+	Register("commits", Commits)
+	Register("commitsMaster", master(Commits))
+	Register("trees", Trees)
+	Register("blobs", Blobs)
+	Register("mostUsedLanguages", MostUsedLanguages)
+	Register("languagesPerRepository", LanguagesPerRepository)
+	Register("languageBreakdown", LanguageBreakdown)
+	Register("languagesPerCommit", LanguagesPerCommit)
+	Register("languagesPerMonth", LanguagesPerMonth)
+	Register("licensesPerRepository", LicensesPerRepository)
+	Register("secrets", Secrets)
+	Register("nearDuplicateFiles", NearDuplicateFiles)
+	Register("sameContentFiles", SameContentFiles)
+	Register("tokenVocabulary", TokenVocabulary)
+	Register("dependencies", Dependencies)
+	Register("commitsPerAuthor", CommitsPerAuthor)
+	Register("commitsPerRepository", CommitsPerRepository)
+	Register("filesPerRepository", FilesPerRepository)
+}
+
+// master runs the query on the master branches only.
+func master(q Query) Query {
+	return func(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+		p.Refs = []string{"refs/heads/master"}
+		return q(f, p)
+	}
+}
+
+// countGroups registers a mapper counting the rows grouped by keys.
+func countGroups(keys ...string) dataset.Mapper {
+	n := len(keys)
+	return dataset.RegisterMapper(keys, append(keys[:n:n], "count"), func(row dataset.Row) error {
+		return gio.Emit(append(row.Values()[:n:n], int64(len(row.Group())))...)
+	})
+}
+
+// distinct registers a mapper emitting the keys of the rows grouped by
+// them, once per group.
+func distinct(keys ...string) dataset.Mapper {
+	n := len(keys)
+	return dataset.RegisterMapper(keys, keys, func(row dataset.Row) error {
+		return gio.Emit(row.Values()[:n]...)
+	})
+}
+
+// top keeps the first rows by the given order, if there is a limit, or
+// sorts all of them.
+func top(d *dataset.Dataset, name string, limit int, orders ...dataset.Order) *dataset.Dataset {
+	if limit > 0 {
+		return d.Top(name, limit, orders...)
+	}
+	return d.Sort(name, orders...)
+}
+
+// headBlobs reads the files of the commits the references p.Refs point to,
+// HEAD if there are none.
+func headBlobs(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	if len(p.Refs) == 0 {
+		p.Refs = []string{"HEAD"}
+	}
+
+	return dataset.Read(f, engine.Repositories(p.Path, p.Partitions).
+		References().Filter(p.Refs...).
+		Commits().
+		Trees().
+		Blobs())
+}
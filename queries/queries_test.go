@@ -0,0 +1,227 @@
+package queries
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	"github.com/chrislusf/gleam/util"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// TestMain lets the test binary run the mappers of the flows, which Gleam
+// runs as separate processes of the binary found in the PATH by its name.
+func TestMain(m *testing.M) {
+	gio.Init()
+
+	if ex, err := os.Executable(); err == nil {
+		os.Setenv("PATH", filepath.Dir(ex)+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
+	os.Exit(m.Run())
+}
+
+// newRepository creates a repository with a commit adding the files, by
+// path, in a new directory under dir.
+func newRepository(t *testing.T, dir, name string, files map[string]string) string {
+	path := filepath.Join(dir, name)
+	if _, err := git.PlainInit(path, false); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, path, "initial commit", "author@example.com", files)
+	return path
+}
+
+// commit writes the files, by path, of the repository at path, removes the
+// removed ones and commits them as the author with the given email,
+// returning the hash of the commit.
+func commit(t *testing.T, path, message, email string, files map[string]string, removed ...string) string {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for file, content := range files {
+		if err := os.MkdirAll(filepath.Join(path, filepath.Dir(file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(path, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range removed {
+		if _, err := w.Remove(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hash, err := w.Commit(message, &git.CommitOptions{Author: &object.Signature{
+		Name:  strings.Split(email, "@")[0],
+		Email: email,
+		When:  time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash.String()
+}
+
+// run runs the query and returns the columns and the rows of its result.
+func run(t *testing.T, q Query, p Params) ([]string, [][]interface{}) {
+	f := flow.New("test")
+	d, err := q(f, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows [][]interface{}
+	d.OutputRow(func(row *util.Row) error {
+		rows = append(rows, append(row.K, row.V...))
+		return nil
+	})
+	f.Run()
+	return d.Columns(), rows
+}
+
+// format formats the values of the columns of the rows, with repository
+// paths relative to dir.
+func format(dir string, columns []string, rows [][]interface{}, names ...string) []string {
+	var lines []string
+	for _, row := range rows {
+		var values []string
+		for _, name := range names {
+			for i, c := range columns {
+				if c != name {
+					continue
+				}
+				v := fmt.Sprint(row[i])
+				if b, ok := row[i].([]byte); ok {
+					v = string(b)
+				}
+				if c == "repositoryID" {
+					v, _ = filepath.Rel(dir, v)
+				}
+				values = append(values, v)
+			}
+		}
+		lines = append(lines, strings.Join(values, " "))
+	}
+	return lines
+}
+
+func TestLanguageBreakdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newRepository(t, dir, "a", map[string]string{
+		"main.go":          "package main\n\nfunc main() {}\n",
+		"script.py":        "print('hello')\n",
+		"README.md":        "# repo\n",
+		"vendor/lib/a.go":  "package lib\n",
+		"config/app.yaml":  "key: value\n",
+		"generated/gen.go": "// Code generated by tool. DO NOT EDIT.\n\npackage gen\n",
+	})
+	newRepository(t, dir, "b", map[string]string{
+		"index.js": "console.log(1)\n",
+	})
+
+	columns, rows := run(t, LanguageBreakdown, Params{Path: dir, Partitions: 2})
+
+	expectedColumns := []string{"repositoryID", "bytes", "lang", "percentage"}
+	if !reflect.DeepEqual(columns, expectedColumns) {
+		t.Errorf("expected columns %v, got %v", expectedColumns, columns)
+	}
+
+	expected := []string{
+		"a Go 29 65.91",
+		"a Python 15 34.09",
+		"b JavaScript 15 100",
+	}
+	got := format(dir, columns, rows, "repositoryID", "lang", "bytes", "percentage")
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected rows %v, got %v", expected, got)
+	}
+}
+
+func TestLanguageCounts(t *testing.T) {
+	dir := newHistory(t)
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name     string
+		query    Query
+		columns  []string
+		expected []string
+	}{
+		{
+			"mostUsedLanguages", MostUsedLanguages,
+			[]string{"count", "lang"},
+			[]string{"3 Go", "2 JavaScript", "1 Python"},
+		},
+		{
+			"languagesPerRepository", LanguagesPerRepository,
+			[]string{"repositoryID", "count", "lang"},
+			[]string{"a 3 Go", "a 1 Python", "b 2 JavaScript"},
+		},
+	}
+
+	for _, c := range cases {
+		columns, rows := run(t, c.query, Params{Path: dir, Partitions: 2})
+		if !reflect.DeepEqual(columns, c.columns) {
+			t.Errorf("expected columns %v of %s, got %v", c.columns, c.name, columns)
+		}
+		got := format(dir, columns, rows, columns...)
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("expected rows %v of %s, got %v", c.expected, c.name, got)
+		}
+	}
+}
+
+func TestSameContentFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newRepository(t, dir, "a", map[string]string{
+		"util.py":    "# helpers\ndef f():\n    return 1\n",
+		"helpers.py": "# other helpers\ndef f():\n    return 1\n",
+	})
+	newRepository(t, dir, "b", map[string]string{
+		"crlf.py": "# helpers\r\ndef f():  \r\n    return 1\r\n",
+	})
+
+	cases := []struct {
+		keepComments bool
+		expected     []string
+	}{
+		{false, []string{"3 3 2"}},
+		{true, []string{"2 2 2"}},
+	}
+	for _, c := range cases {
+		p := Params{Path: dir, Partitions: 2, KeepComments: c.keepComments}
+		columns, rows := run(t, SameContentFiles, p)
+		got := format(dir, columns, rows, "files", "blobs", "repositories")
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("expected rows %v keeping comments %v, got %v", c.expected, c.keepComments, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package queries
+
+import (
+	"github.com/chrislusf/gleam/flow"
+	engine "github.com/eiso/go-engine"
+	"github.com/eiso/go-engine/dataset"
+)
+
+var (
+	distinctCommits     = distinct("repositoryID", "commitHash", "authorEmail")
+	distinctFiles       = distinct("repositoryID", "path")
+	countAuthorCommits  = countGroups("authorEmail")
+	countRepositoryRows = countGroups("repositoryID")
+)
+
+// history reads the commits reachable from the references p.Refs, each of
+// them once even when several references reach it.
+func history(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	commits, err := dataset.Read(f, engine.Repositories(p.Path, p.Partitions).
+		References().Filter(p.Refs...).
+		AllReferenceCommits())
+	if err != nil {
+		return nil, err
+	}
+
+	return commits.
+		Select("commits", "repositoryID", "commitHash", "authorEmail").
+		GroupBy("group by commit", "repositoryID", "commitHash", "authorEmail").
+		Map("distinct commits", distinctCommits), nil
+}
+
+// CommitsPerAuthor counts the commits of every author email in the history
+// of the references p.Refs, keeping the p.Limit authors with more commits.
+func CommitsPerAuthor(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	commits, err := history(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return top(commits.
+		Select("authors", "authorEmail").
+		GroupBy("group by author", "authorEmail").
+		Map("count commits", countAuthorCommits),
+		"top authors", p.Limit, dataset.Desc("count")), nil
+}
+
+// CommitsPerRepository counts the commits in the history of the references
+// p.Refs of every repository, keeping the p.Limit repositories with more
+// commits.
+func CommitsPerRepository(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	commits, err := history(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return top(commits.
+		Select("repositories", "repositoryID").
+		GroupBy("group by repository", "repositoryID").
+		Map("count commits", countRepositoryRows),
+		"top repositories", p.Limit, dataset.Desc("count")), nil
+}
+
+// FilesPerRepository counts the distinct paths of the files of every
+// repository in the commits the references p.Refs point to, keeping the
+// p.Limit repositories with more files. Directories are not counted, only
+// the files in them.
+func FilesPerRepository(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	blobs, err := dataset.Read(f, engine.Repositories(p.Path, p.Partitions).
+		References().Filter(p.Refs...).
+		Commits().
+		Trees().
+		Blobs())
+	if err != nil {
+		return nil, err
+	}
+
+	return top(blobs.
+		Select("files", "repositoryID", "path").
+		GroupBy("group by file", "repositoryID", "path").
+		Map("distinct files", distinctFiles).
+		Select("repositories", "repositoryID").
+		GroupBy("group by repository", "repositoryID").
+		Map("count files", countRepositoryRows),
+		"top repositories", p.Limit, dataset.Desc("count")), nil
+}
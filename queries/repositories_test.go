@@ -0,0 +1,78 @@
+package queries
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// newHistory creates the repositories the queries about their history are
+// tested with:
+//
+//	a: three commits, two of them by alice, with files in nested directories
+//	b: an empty commit and a commit by alice
+func newHistory(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newRepository(t, dir, "a", map[string]string{
+		"main.go":          "package main\n",
+		"lib/util.go":      "package lib\n",
+		"lib/deep/deep.go": "package deep\n",
+	})
+	commit(t, a, "add script", "alice@example.com", map[string]string{
+		"scripts/run.py": "print(1)\n",
+	})
+	commit(t, a, "change main", "alice@example.com", map[string]string{
+		"main.go": "package main\n\nfunc main() {}\n",
+	})
+
+	b := newRepository(t, dir, "b", nil)
+	commit(t, b, "add index", "alice@example.com", map[string]string{
+		"index.js": "console.log(1)\n",
+		"lib.js":   "module.exports = {}\n",
+	})
+	return dir
+}
+
+func TestHistoryQueries(t *testing.T) {
+	dir := newHistory(t)
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name     string
+		query    Query
+		columns  []string
+		expected []string
+	}{
+		{
+			"commitsPerAuthor", CommitsPerAuthor,
+			[]string{"count", "authorEmail"},
+			[]string{"3 alice@example.com", "2 author@example.com"},
+		},
+		{
+			"commitsPerRepository", CommitsPerRepository,
+			[]string{"count", "repositoryID"},
+			[]string{"3 a", "2 b"},
+		},
+		{
+			"filesPerRepository", FilesPerRepository,
+			[]string{"count", "repositoryID"},
+			[]string{"4 a", "2 b"},
+		},
+	}
+
+	for _, c := range cases {
+		columns, rows := run(t, c.query, Params{Path: dir, Partitions: 2, Refs: []string{"refs/heads/master"}})
+		if !reflect.DeepEqual(columns, c.columns) {
+			t.Errorf("expected columns %v of %s, got %v", c.columns, c.name, columns)
+		}
+		got := format(dir, columns, rows, columns...)
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("expected rows %v of %s, got %v", c.expected, c.name, got)
+		}
+	}
+}
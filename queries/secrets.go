@@ -0,0 +1,91 @@
+package queries
+
+import (
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	engine "github.com/eiso/go-engine"
+	"github.com/eiso/go-engine/dataset"
+	"github.com/eiso/go-engine/readers"
+	"github.com/eiso/go-engine/secrets"
+	"github.com/eiso/go-engine/udf"
+)
+
+// historyBlobColumns are the columns of the blobs of historyBlobs, the
+// scanners read the content from the last one.
+var historyBlobColumns = []string{"repositoryID", "blobHash", "commitHash", "fileName", "content"}
+
+// SecretsColumns are the columns of the result of ScanHistory.
+var SecretsColumns = []string{"repositoryID", "blobHash", "commitHash", "fileName", "ruleID", "line", "match"}
+
+var (
+	// firstBlobs keeps one of the commits and paths of every blob.
+	firstBlobs = dataset.RegisterMapper(
+		[]string{"repositoryID", "blobHash"},
+		[]string{"repositoryID", "blobHash", "commitHash", "fileName"},
+		func(row dataset.Row) error {
+			first := row.Group()[0]
+			return gio.Emit(row.Get("repositoryID"), row.Get("blobHash"), first[0], first[1])
+		})
+
+	readHistoryBlobs = gio.RegisterMapper(udf.ReadBlob(0, 1))
+
+	scanDefaultSecrets = NewSecretScanner()
+)
+
+// NewSecretScanner registers the mapper scanning the blobs of ScanHistory
+// with the rules, secrets.DefaultRules if there are none. Like every
+// mapper, it must be registered when the program starts, in the same order
+// in the driver and the agents.
+func NewSecretScanner(rules ...secrets.Rule) gio.MapperId {
+	return gio.RegisterMapper(udf.ScanSecrets(len(historyBlobColumns)-1, rules...))
+}
+
+// historyBlobs reads every blob in the history of the references p.Refs
+// once, with one of the commits and paths it is found at, no matter how
+// many commits it is in. The entries of directories and submodules are
+// skipped, their hashes are not the ones of blobs.
+func historyBlobs(f *flow.Flow, p Params) (*flow.Dataset, error) {
+	trees, err := dataset.Read(f, engine.Repositories(p.Path, p.Partitions).
+		References().Filter(p.Refs...).
+		AllReferenceCommits().
+		Trees().
+		Where(readers.Condition{Column: "isFile", Op: readers.OpEq, Values: []interface{}{true}}))
+	if err != nil {
+		return nil, err
+	}
+
+	return trees.
+		Select("files", "repositoryID", "blobHash", "commitHash", "fileName").
+		GroupBy("group by blob", "repositoryID", "blobHash").
+		Map("distinct blobs", firstBlobs).
+		Dataset.Map("read blobs", readHistoryBlobs), nil
+}
+
+// ScanHistory scans for secrets every blob in the history of the
+// references p.Refs with the scanner, see NewSecretScanner, so secrets
+// removed by later commits are found too. Blobs are read and scanned once,
+// and the findings have one of the commits and paths of the blob, see
+// SecretsColumns. p.Limit is not used.
+func ScanHistory(f *flow.Flow, p Params, scanner gio.MapperId) (*dataset.Dataset, error) {
+	blobs, err := historyBlobs(f, p)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := append(historyBlobColumns[:len(historyBlobColumns):len(historyBlobColumns)], SecretsColumns[4:]...)
+	return dataset.New(blobs.Map("scan secrets", scanner), columns...).
+		Select("findings", SecretsColumns...), nil
+}
+
+// Secrets scans every blob in the history of the references p.Refs for
+// the secrets of secrets.DefaultRules, see ScanHistory, sorted by
+// repository. p.Limit applies to the whole result.
+func Secrets(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	findings, err := ScanHistory(f, p, scanDefaultSecrets)
+	if err != nil {
+		return nil, err
+	}
+
+	return top(findings, "sort findings", p.Limit,
+		dataset.Asc("repositoryID"), dataset.Asc("fileName"), dataset.Asc("line")), nil
+}
@@ -0,0 +1,198 @@
+package queries
+
+import (
+	"io/ioutil"
+	"sort"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	engine "github.com/eiso/go-engine"
+	"github.com/eiso/go-engine/dataset"
+	"github.com/eiso/go-engine/udf"
+	"github.com/pkg/errors"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+var (
+	distinctReferences = distinct("repositoryID", "refHash")
+
+	snapshotsPerCommit = languageSnapshots(false)
+	snapshotsPerMonth  = languageSnapshots(true)
+)
+
+// languageFile is a file counted in the snapshots.
+type languageFile struct {
+	lang string
+	size int64
+}
+
+// languageSnapshots registers a mapper emitting the bytes of every
+// language at every commit of the first parent history of the references,
+// or at the last commit of every month if monthly is true. The language
+// of the files is only classified when they change.
+func languageSnapshots(monthly bool) dataset.Mapper {
+	return dataset.RegisterMapper(
+		[]string{"repositoryID", "refHash"},
+		[]string{"repositoryID", "commitHash", "date", "lang", "bytes"},
+		func(row dataset.Row) error {
+			repositoryID := row.String("repositoryID")
+			repo, err := udf.Repository(repositoryID)
+			if err != nil {
+				return errors.Wrapf(err, "could not open repo at %s", repositoryID)
+			}
+
+			commits, err := firstParents(repo, plumbing.NewHash(row.String("refHash")))
+			if err != nil {
+				return errors.Wrapf(err, "could not read the history of %s", repositoryID)
+			}
+
+			files := make(map[string]languageFile)
+			var prev *object.Tree
+			for i, c := range commits {
+				tree, err := c.Tree()
+				if err != nil {
+					return errors.Wrapf(err, "could not read the tree of commit %s", c.Hash)
+				}
+				if err := updateLanguageFiles(files, prev, tree); err != nil {
+					return errors.Wrapf(err, "could not diff commit %s", c.Hash)
+				}
+				prev = tree
+
+				if monthly && i+1 < len(commits) && month(commits[i+1]) == month(c) {
+					continue
+				}
+				for _, l := range languageBytes(files) {
+					err := gio.Emit(repositoryID, c.Hash.String(), c.Committer.When.Unix(), l.lang, l.size)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+}
+
+// firstParents returns the commits of the first parent history of the
+// commit, from the oldest one, like the main branch without the commits
+// of the branches merged into it.
+func firstParents(repo *git.Repository, hash plumbing.Hash) ([]*object.Commit, error) {
+	var commits []*object.Commit
+	for {
+		c, err := repo.CommitObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, c)
+
+		if len(c.ParentHashes) == 0 {
+			break
+		}
+		hash = c.ParentHashes[0]
+	}
+
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	return commits, nil
+}
+
+// updateLanguageFiles classifies the files changed from the tree prev,
+// nil for the first commit, to the tree, and forgets the ones removed.
+func updateLanguageFiles(files map[string]languageFile, prev, tree *object.Tree) error {
+	changes, err := object.DiffTree(prev, tree)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		delete(files, change.From.Name)
+
+		_, to, err := change.Files()
+		if err != nil {
+			return err
+		}
+		// deleted, or not a regular file, like submodules
+		if to == nil {
+			continue
+		}
+
+		binary, err := to.IsBinary()
+		if err != nil {
+			return err
+		}
+
+		r, err := to.Reader()
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+
+		// the name of the change is the full path of the file
+		path := change.To.Name
+		if lang := linguistLanguage(path, content, binary); lang != "" {
+			files[path] = languageFile{lang: lang, size: to.Size}
+		}
+	}
+	return nil
+}
+
+// languageBytes sums the sizes of the files of every language, sorted by
+// language.
+func languageBytes(files map[string]languageFile) []languageFile {
+	sizes := make(map[string]int64)
+	for _, f := range files {
+		sizes[f.lang] += f.size
+	}
+
+	langs := make([]languageFile, 0, len(sizes))
+	for lang, size := range sizes {
+		langs = append(langs, languageFile{lang: lang, size: size})
+	}
+	sort.Slice(langs, func(i, j int) bool { return langs[i].lang < langs[j].lang })
+	return langs
+}
+
+func month(c *object.Commit) string {
+	return c.Committer.When.UTC().Format("2006-01")
+}
+
+// LanguagesPerCommit computes the bytes of every language, counted like
+// LanguageBreakdown, at every commit of the first parent history of the
+// references p.Refs, HEAD if there are none, to chart the languages of
+// repositories over time. Dates are Unix timestamps. The files are only
+// classified in the commits changing them, instead of reading the whole
+// tree of every commit. p.Limit applies to the whole result.
+func LanguagesPerCommit(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	return languagesOverTime(f, p, snapshotsPerCommit)
+}
+
+// LanguagesPerMonth is like LanguagesPerCommit but only for the last
+// commit of every month.
+func LanguagesPerMonth(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	return languagesOverTime(f, p, snapshotsPerMonth)
+}
+
+func languagesOverTime(f *flow.Flow, p Params, snapshots dataset.Mapper) (*dataset.Dataset, error) {
+	if len(p.Refs) == 0 {
+		p.Refs = []string{"HEAD"}
+	}
+
+	refs, err := dataset.Read(f, engine.Repositories(p.Path, p.Partitions).
+		References().Filter(p.Refs...))
+	if err != nil {
+		return nil, err
+	}
+
+	return top(refs.
+		Select("references", "repositoryID", "refHash").
+		GroupBy("group by reference", "repositoryID", "refHash").
+		Map("distinct references", distinctReferences).
+		Map("language snapshots", snapshots),
+		"sort snapshots", p.Limit, dataset.Asc("repositoryID"), dataset.Asc("date"), dataset.Asc("lang")), nil
+}
@@ -0,0 +1,72 @@
+package queries
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLanguagesPerCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	breakdown := func() []string {
+		columns, rows := run(t, LanguageBreakdown, Params{Path: dir, Partitions: 1})
+		langs := format(dir, columns, rows, "lang", "bytes")
+		sort.Strings(langs)
+		return langs
+	}
+
+	// the languages of every commit, as LanguageBreakdown finds them
+	var (
+		commits  []string
+		expected = make(map[string][]string)
+	)
+	add := func(hash string) {
+		commits = append(commits, hash)
+		expected[hash] = breakdown()
+	}
+
+	a := newRepository(t, dir, "a", nil)
+	add(commit(t, a, "add main", "author@example.com", map[string]string{
+		"main.go":   "package main\n",
+		"script.py": "print('hello')\n",
+		"README.md": "# repo\n",
+	}))
+	add(commit(t, a, "add lib", "author@example.com", map[string]string{
+		"main.go":        "package main\n\nfunc main() {}\n",
+		"lib/util.go":    "package lib\n",
+		"vendor/v/v.go":  "package v\n",
+		"lib/helpers.py": "def f():\n    return 1\n",
+	}))
+	add(commit(t, a, "remove the script", "author@example.com", nil, "script.py"))
+	add(commit(t, a, "rename util", "author@example.com", map[string]string{
+		"lib/strings.go": "package lib\n",
+	}, "lib/util.go"))
+	add(commit(t, a, "move helpers to a script", "author@example.com", map[string]string{
+		"lib/helpers.rb": "def f\n  1\nend\n",
+	}, "lib/helpers.py", "main.go"))
+
+	columns, rows := run(t, LanguagesPerCommit, Params{Path: dir, Partitions: 1})
+	got := make(map[string][]string)
+	for _, row := range format(dir, columns, rows, "commitHash", "lang", "bytes") {
+		fields := strings.SplitN(row, " ", 2)
+		got[fields[0]] = append(got[fields[0]], fields[1])
+	}
+
+	// the first commit, which is empty, has no languages
+	if len(got) != len(commits) {
+		t.Errorf("expected snapshots of %d commits, got %d", len(commits), len(got))
+	}
+	for i, hash := range commits {
+		if !reflect.DeepEqual(got[hash], expected[hash]) {
+			t.Errorf("expected the languages %v at commit %d, got %v", expected[hash], i+1, got[hash])
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package queries
+
+import (
+	"github.com/chrislusf/gleam/flow"
+	engine "github.com/eiso/go-engine"
+	"github.com/eiso/go-engine/dataset"
+)
+
+// Repositories reads the repositories at p.Path.
+func Repositories(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	return dataset.Read(f, engine.Repositories(p.Path, p.Partitions))
+}
+
+// References reads the references p.Refs of the repositories.
+func References(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	return dataset.Read(f, engine.Repositories(p.Path, p.Partitions).
+		References().Filter(p.Refs...))
+}
+
+// Commits reads the commits the references p.Refs point to.
+func Commits(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	return dataset.Read(f, engine.Repositories(p.Path, p.Partitions).
+		References().Filter(p.Refs...).
+		Commits())
+}
+
+// Trees reads the tree entries of the commits the references p.Refs point
+// to.
+func Trees(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	return dataset.Read(f, engine.Repositories(p.Path, p.Partitions).
+		References().Filter(p.Refs...).
+		Commits().
+		Trees())
+}
+
+// Blobs reads the blobs of the commits the references p.Refs point to.
+func Blobs(f *flow.Flow, p Params) (*dataset.Dataset, error) {
+	return dataset.Read(f, engine.Repositories(p.Path, p.Partitions).
+		References().Filter(p.Refs...).
+		Commits().
+		Trees().
+		Blobs())
+}
@@ -0,0 +1,46 @@
+package queries
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSources(t *testing.T) {
+	dir := newHistory(t)
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name     string
+		query    Query
+		refs     []string
+		columns  []string
+		expected []string
+	}{
+		{
+			"repositories", Repositories, nil,
+			[]string{"repositoryID", "repositoryType"},
+			[]string{"a standard", "b standard"},
+		},
+		{
+			"references", References, nil,
+			[]string{"repositoryID", "refName", "isRemote"},
+			[]string{"a refs/heads/master false", "b refs/heads/master false"},
+		},
+		{
+			"commits", Commits, []string{"refs/heads/master"},
+			[]string{"repositoryID", "message", "authorEmail", "parentsCount"},
+			[]string{"a change main alice@example.com 1", "b add index alice@example.com 1"},
+		},
+	}
+
+	for _, c := range cases {
+		columns, rows := run(t, c.query, Params{Path: dir, Partitions: 2, Refs: c.refs})
+		got := format(dir, columns, rows, c.columns...)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("expected rows %q of %s, got %q", c.expected, c.name, got)
+		}
+	}
+}
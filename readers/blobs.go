@@ -2,6 +2,7 @@ package readers
 
 import (
 	"io"
+	"io/ioutil"
 
 	"github.com/chrislusf/gleam/util"
 	"github.com/pkg/errors"
@@ -25,16 +26,23 @@ func NewBlobs(r *git.Repository, path string, commitsIter object.CommitIter) (*B
 	}, nil
 }
 
+// BlobsSchema is the schema of the rows read by Blobs.
+var BlobsSchema = Schema{
+	{Name: "repositoryID", Type: String},
+	{Name: "blobHash", Type: String},
+	{Name: "commitHash", Type: String},
+	{Name: "content", Type: Bytes},
+	{Name: "path", Type: String},
+	{Name: "isBinary", Type: Bool},
+	{Name: "blobSize", Type: Int64},
+}
+
+func (r *Blobs) Schema() Schema {
+	return BlobsSchema
+}
+
 func (r *Blobs) ReadHeader() ([]string, error) {
-	return []string{
-		"repositoryID",
-		"blobHash",
-		"commitHash",
-		"content",
-		"path",
-		"isBinary",
-		"blobSize",
-	}, nil
+	return BlobsSchema.Names(), nil
 }
 
 func (r *Blobs) Read() (*util.Row, error) {
@@ -59,10 +67,15 @@ func (r *Blobs) Read() (*util.Row, error) {
 		return nil, errors.Wrap(err, "could not get next file")
 	}
 
-	content, err := file.Contents()
+	reader, err := file.Reader()
 	if err != nil {
 		return nil, errors.Wrap(err, "could not get file content")
 	}
+	content, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read file content")
+	}
 
 	binary, err := file.IsBinary()
 	if err != nil {
@@ -2,10 +2,12 @@ package readers
 
 import (
 	"io"
+	"strings"
 
 	"github.com/chrislusf/gleam/util"
 	"github.com/pkg/errors"
 	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	storer "gopkg.in/src-d/go-git.v4/plumbing/storer"
 )
@@ -18,32 +20,47 @@ type Commits struct {
 	commitsIter  object.CommitIter
 	refsIter     storer.ReferenceIter
 	all          bool
+	excludeRefs  []string
 }
 
-func NewCommits(repo *git.Repository, path string, refsIter storer.ReferenceIter, all bool) (*Commits, error) {
+// NewCommits returns a reader for the commits the references point to, or
+// for all the commits reachable from them when all is true. In that case
+// every commit is read once, even if it is reachable from several
+// references, and commits reachable from excludeRefs are not read at all,
+// so that disjoint ranges of history can be read by different shards.
+func NewCommits(repo *git.Repository, path string, refsIter storer.ReferenceIter, all bool, excludeRefs []string) (*Commits, error) {
 	return &Commits{
 		repositoryID: path,
 		repo:         repo,
 		refsIter:     refsIter,
 		all:          all,
+		excludeRefs:  excludeRefs,
 	}, nil
 }
 
+// CommitsSchema is the schema of the rows read by Commits. The parent
+// hashes are joined by commas and dates are Unix timestamps.
+var CommitsSchema = Schema{
+	{Name: "repositoryID", Type: String},
+	{Name: "commitHash", Type: String},
+	{Name: "treeHash", Type: String},
+	{Name: "parentHashes", Type: String},
+	{Name: "parentsCount", Type: Int64},
+	{Name: "message", Type: String},
+	{Name: "authorEmail", Type: String},
+	{Name: "authorName", Type: String},
+	{Name: "authorDate", Type: Int64},
+	{Name: "committerEmail", Type: String},
+	{Name: "committerName", Type: String},
+	{Name: "committerDate", Type: Int64},
+}
+
+func (r *Commits) Schema() Schema {
+	return CommitsSchema
+}
+
 func (r *Commits) ReadHeader() ([]string, error) {
-	return []string{
-		"repositoryID",
-		"commitHash",
-		"treeHash",
-		"parentHashes",
-		"parentsCount",
-		"message",
-		"authorEmail",
-		"authorName",
-		"authorDate",
-		"committerEmail",
-		"committerName",
-		"committerDate",
-	}, nil
+	return CommitsSchema.Names(), nil
 }
 
 func (r *Commits) Read() (*util.Row, error) {
@@ -56,10 +73,17 @@ func (r *Commits) Read() (*util.Row, error) {
 		return nil, err
 	}
 
+	parents := make([]string, len(commit.ParentHashes))
+	for i, h := range commit.ParentHashes {
+		parents[i] = h.String()
+	}
+
 	return util.NewRow(util.Now(),
 		r.repositoryID,
 		commit.Hash.String(),
 		commit.TreeHash.String(),
+		strings.Join(parents, ","),
+		int64(len(parents)),
 		commit.Message,
 		commit.Author.Email,
 		commit.Author.Name,
@@ -73,8 +97,9 @@ func (r *Commits) Read() (*util.Row, error) {
 func (r *Commits) GetIter() object.CommitIter {
 	if r.all {
 		return &allCommitsIterator{
-			repo:     r.repo,
-			refsIter: r.refsIter,
+			repo:        r.repo,
+			refsIter:    r.refsIter,
+			excludeRefs: r.excludeRefs,
 		}
 	}
 	return &commitsIterator{
@@ -146,9 +171,21 @@ type allCommitsIterator struct {
 	repo        *git.Repository
 	refsIter    storer.ReferenceIter
 	commitsIter object.CommitIter
+	excludeRefs []string
+	// seen holds the commits already read and the excluded ones, it is
+	// shared by the walks of all the references to not read history twice.
+	seen map[plumbing.Hash]bool
 }
 
 func (iter *allCommitsIterator) Next() (*object.Commit, error) {
+	if iter.seen == nil {
+		seen, err := reachableCommits(iter.repo, iter.excludeRefs)
+		if err != nil {
+			return nil, err
+		}
+		iter.seen = seen
+	}
+
 	if iter.commitsIter == nil {
 		ref, err := iter.refsIter.Next()
 		if err != nil {
@@ -160,18 +197,24 @@ func (iter *allCommitsIterator) Next() (*object.Commit, error) {
 			return nil, err
 		}
 
-		iter.commitsIter, err = iter.repo.Log(&git.LogOptions{From: refCommitHash})
+		commit, err := iter.repo.CommitObject(refCommitHash)
 		if err != nil {
-			return nil, err
+			return nil, ErrObj
 		}
+
+		iter.commitsIter = object.NewCommitPreorderIter(commit, iter.seen, nil)
 	}
 
 	commit, err := iter.commitsIter.Next()
 	if err == io.EOF {
 		iter.commitsIter = nil
 		return iter.Next()
+	} else if err != nil {
+		return nil, err
 	}
-	return commit, err
+
+	iter.seen[commit.Hash] = true
+	return commit, nil
 }
 
 // ForEach call the cb function for each reference contained on this iter until
@@ -201,3 +244,29 @@ func (iter *allCommitsIterator) ForEach(cb func(*object.Commit) error) error {
 }
 
 func (iter *allCommitsIterator) Close() {}
+
+// reachableCommits returns the set of commits reachable from the given
+// revisions, which can be reference names or commit hashes.
+func reachableCommits(repo *git.Repository, revisions []string) (map[plumbing.Hash]bool, error) {
+	seen := make(map[plumbing.Hash]bool)
+	for _, rev := range revisions {
+		hash, err := resolveRevision(repo, rev)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not resolve excluded revision %s", rev)
+		}
+
+		commit, err := repo.CommitObject(hash)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not find excluded commit %s", hash)
+		}
+
+		err = object.NewCommitPreorderIter(commit, seen, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not walk history of %s", rev)
+		}
+	}
+	return seen, nil
+}
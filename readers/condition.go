@@ -0,0 +1,160 @@
+package readers
+
+import (
+	"bytes"
+
+	"github.com/chrislusf/gleam/util"
+	"github.com/pkg/errors"
+)
+
+// Operators of conditions.
+const (
+	OpEq      = "="
+	OpNotEq   = "!="
+	OpLess    = "<"
+	OpLessEq  = "<="
+	OpMore    = ">"
+	OpMoreEq  = ">="
+	OpIn      = "IN"
+	OpNotIn   = "NOT IN"
+	OpLike    = "LIKE"
+	OpNotLike = "NOT LIKE"
+)
+
+// Condition is a predicate on a column of the rows read. Conditions are
+// evaluated where the repositories are read, so the rows that don't match
+// them never leave the reader.
+type Condition struct {
+	Column string
+	Op     string
+	// Values holds a single value for every operator but IN and NOT IN. LIKE
+	// patterns are strings where % matches any text and _ any character.
+	Values []interface{}
+}
+
+// Match reports whether the row matches all the conditions.
+func (s Schema) Match(row *util.Row, conditions []Condition) (bool, error) {
+	for _, c := range conditions {
+		i := s.Index(c.Column)
+		if i < 0 {
+			return false, errors.Errorf("unknown column %s", c.Column)
+		}
+
+		var v interface{}
+		if i < len(row.K) {
+			v = row.K[i]
+		} else if i-len(row.K) < len(row.V) {
+			v = row.V[i-len(row.K)]
+		}
+
+		ok, err := c.Match(v)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Match reports whether the value satisfies the condition.
+func (c Condition) Match(v interface{}) (bool, error) {
+	switch c.Op {
+	case OpIn, OpNotIn:
+		in := false
+		for _, value := range c.Values {
+			if equals(v, value) {
+				in = true
+				break
+			}
+		}
+		return in == (c.Op == OpIn), nil
+	}
+
+	if len(c.Values) != 1 {
+		return false, errors.Errorf("%s needs exactly one value", c.Op)
+	}
+	value := c.Values[0]
+	if v == nil || value == nil {
+		// like in SQL, nothing is ever equal or comparable to null
+		return false, nil
+	}
+
+	switch c.Op {
+	case OpEq:
+		return equals(v, value), nil
+	case OpNotEq:
+		return !equals(v, value), nil
+	case OpLike, OpNotLike:
+		return like(util.ToString(v), util.ToString(value)) == (c.Op == OpLike), nil
+	}
+
+	if _, ok := v.(bool); ok {
+		return false, errors.Errorf("booleans can't be compared with %s", c.Op)
+	}
+	cmp := util.Compare(v, value)
+	switch c.Op {
+	case OpLess:
+		return cmp < 0, nil
+	case OpLessEq:
+		return cmp <= 0, nil
+	case OpMore:
+		return cmp > 0, nil
+	case OpMoreEq:
+		return cmp >= 0, nil
+	}
+	return false, errors.Errorf("unknown operator %q", c.Op)
+}
+
+func equals(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	switch x := a.(type) {
+	case bool:
+		y, ok := b.(bool)
+		return ok && x == y
+	case string, []byte:
+		switch b.(type) {
+		case string, []byte:
+			return bytes.Equal(util.ToBytes(a), util.ToBytes(b))
+		}
+		return false
+	}
+
+	switch b.(type) {
+	case bool, string, []byte:
+		return false
+	}
+	return util.Compare(a, b) == 0
+}
+
+// like matches s against a SQL LIKE pattern. It only goes back to the last
+// % seen when the rest of the pattern does not match, so it takes linear
+// time in practice and O(len(s)*len(pattern)) at worst, even for patterns
+// with many %.
+func like(s, pattern string) bool {
+	var i, j int
+	// the position of the last % in the pattern, and of the byte of s it
+	// matches up to
+	star, mark := -1, 0
+	for i < len(s) {
+		switch {
+		case j < len(pattern) && pattern[j] == '%':
+			star, mark = j, i
+			j++
+		case j < len(pattern) && (pattern[j] == '_' || pattern[j] == s[i]):
+			i++
+			j++
+		case star >= 0:
+			mark++
+			i, j = mark, star+1
+		default:
+			return false
+		}
+	}
+
+	for j < len(pattern) && pattern[j] == '%' {
+		j++
+	}
+	return j == len(pattern)
+}
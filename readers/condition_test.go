@@ -0,0 +1,48 @@
+package readers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLike(t *testing.T) {
+	cases := []struct {
+		s, pattern string
+		match      bool
+	}{
+		{"", "", true},
+		{"", "%", true},
+		{"a", "", false},
+		{"master", "master", true},
+		{"master", "mast", false},
+		{"refs/heads/master", "refs/heads/%", true},
+		{"refs/tags/v1", "refs/heads/%", false},
+		{"main.go", "%.go", true},
+		{"main.go.orig", "%.go", false},
+		{"abc", "a_c", true},
+		{"ac", "a_c", false},
+		{"abc", "%%a%%b%%c%%", true},
+		{"axbxcxd", "%a%b%c%d%", true},
+		{"axbxcx", "%a%b%c%d%", false},
+		{"aaab", "%aab", true},
+		{"100%", "100%", true},
+		{"%", "_", true},
+	}
+
+	for _, c := range cases {
+		if got := like(c.s, c.pattern); got != c.match {
+			t.Errorf("like(%q, %q) = %v, expected %v", c.s, c.pattern, got, c.match)
+		}
+	}
+}
+
+func TestLikeManyWildcards(t *testing.T) {
+	// exponential when every % is tried against every position
+	s := strings.Repeat("a", 100000)
+	if like(s, "%a%b%c%d%") {
+		t.Error("expected no match")
+	}
+	if !like(s+"bcd", "%a%b%c%d%") {
+		t.Error("expected a match")
+	}
+}
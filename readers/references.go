@@ -1,8 +1,8 @@
 package readers
 
 import (
+	"encoding/hex"
 	"io"
-	"strconv"
 
 	"github.com/chrislusf/gleam/util"
 	"github.com/pkg/errors"
@@ -28,13 +28,20 @@ func NewReferences(repo *git.Repository, path string, onlyRefs []string) (*Refer
 	}, nil
 }
 
+// ReferencesSchema is the schema of the rows read by References.
+var ReferencesSchema = Schema{
+	{Name: "repositoryID", Type: String},
+	{Name: "refHash", Type: String},
+	{Name: "refName", Type: String},
+	{Name: "isRemote", Type: Bool},
+}
+
+func (r *References) Schema() Schema {
+	return ReferencesSchema
+}
+
 func (r *References) ReadHeader() ([]string, error) {
-	return []string{
-		"repositoryID",
-		"refHash",
-		"refName",
-		"isRemote",
-	}, nil
+	return ReferencesSchema.Names(), nil
 }
 
 func (r *References) Read() (*util.Row, error) {
@@ -61,7 +68,7 @@ func (r *References) Read() (*util.Row, error) {
 		r.repositoryID,
 		refCommitHash.String(),
 		ref.Name().String(),
-		strconv.FormatBool(ref.Name().IsRemote()),
+		ref.Name().IsRemote(),
 	), nil
 }
 
@@ -105,6 +112,11 @@ func (iter *refIterator) Next() (*plumbing.Reference, error) {
 	refName := iter.refNames[iter.pos]
 	iter.pos++
 
+	// commit hashes are read as if a reference pointed to them
+	if isHash(string(refName)) {
+		return plumbing.NewHashReference(refName, plumbing.NewHash(string(refName))), nil
+	}
+
 	ref, err := iter.repo.Reference(refName, true)
 	if err != nil {
 		// If ReferenceName does not exist, skip it
@@ -141,6 +153,28 @@ func (iter *refIterator) ForEach(cb func(*plumbing.Reference) error) error {
 
 func (iter *refIterator) Close() {}
 
+// resolveRevision returns the commit a reference name or a commit hash
+// points to.
+func resolveRevision(repo *git.Repository, rev string) (plumbing.Hash, error) {
+	if isHash(rev) {
+		return plumbing.NewHash(rev), nil
+	}
+
+	ref, err := repo.Reference(plumbing.ReferenceName(rev), true)
+	if err != nil {
+		return plumbing.NewHash(""), ErrRef
+	}
+	return resolveRef(repo, ref)
+}
+
+func isHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
 // Get correct commit hash
 // there is Repository.ResolveRevision but it fails on some tags and performance is worst
 func resolveRef(repo *git.Repository, ref *plumbing.Reference) (plumbing.Hash, error) {
@@ -2,29 +2,46 @@ package readers
 
 import (
 	"io"
+	"strings"
 
 	"github.com/chrislusf/gleam/util"
 	git "gopkg.in/src-d/go-git.v4"
 )
 
 type Repositories struct {
-	repositoryID string
-	repos        *reposIter
+	repositoryID   string
+	repositoryType string
+	repositoryURL  string
+	repos          *reposIter
 }
 
-func NewRepositories(repo *git.Repository, path string) (*Repositories, error) {
+// NewRepositories returns a reader for the repository at path. When url is
+// empty the URLs of the repository remotes are reported instead.
+func NewRepositories(repo *git.Repository, path, repoType, url string) (*Repositories, error) {
 	return &Repositories{
-		repos:        &reposIter{repos: []*git.Repository{repo}},
-		repositoryID: path,
+		repos:          &reposIter{repos: []*git.Repository{repo}},
+		repositoryID:   path,
+		repositoryType: repoType,
+		repositoryURL:  url,
 	}, nil
 }
 
+// RepositoriesSchema is the schema of the rows read by Repositories. The
+// URLs are joined by commas, and headRef is empty when HEAD can't be
+// resolved.
+var RepositoriesSchema = Schema{
+	{Name: "repositoryID", Type: String},
+	{Name: "repositoryURLs", Type: String},
+	{Name: "headRef", Type: String},
+	{Name: "repositoryType", Type: String},
+}
+
+func (r *Repositories) Schema() Schema {
+	return RepositoriesSchema
+}
+
 func (r *Repositories) ReadHeader() (fieldNames []string, err error) {
-	return []string{
-		"repositoryID",
-		"repositoryURLs",
-		"headRef",
-	}, nil
+	return RepositoriesSchema.Names(), nil
 }
 
 //TODO: add is_fork
@@ -49,6 +66,11 @@ func (r *Repositories) Read() (*util.Row, error) {
 	// 		listRemotes[k].Config().Fetch)
 	// }
 
+	urls := []string{r.repositoryURL}
+	if r.repositoryURL == "" {
+		urls = remoteURLs(repository)
+	}
+
 	var headHash string
 	// Errors are not handles since some repositories can have an empty/unresolvable HEAD
 	head, err := repository.Head()
@@ -60,13 +82,32 @@ func (r *Repositories) Read() (*util.Row, error) {
 		}
 	}
 
-	return util.NewRow(util.Now(), r.repositoryID, headHash), nil
+	return util.NewRow(util.Now(),
+		r.repositoryID,
+		strings.Join(urls, ","),
+		headHash,
+		r.repositoryType,
+	), nil
 }
 
 func (r *Repositories) Close() error {
 	return nil
 }
 
+// remoteURLs returns the URLs of all the remotes of the repository. Errors are
+// not handled since the URLs are informative only.
+func remoteURLs(repository *git.Repository) []string {
+	var urls []string
+	remotes, err := repository.Remotes()
+	if err != nil {
+		return nil
+	}
+	for _, remote := range remotes {
+		urls = append(urls, remote.Config().URLs...)
+	}
+	return urls
+}
+
 type reposIter struct {
 	repos []*git.Repository
 	pos   int
@@ -0,0 +1,93 @@
+package readers
+
+import (
+	"reflect"
+
+	"github.com/chrislusf/gleam/util"
+	"github.com/pkg/errors"
+)
+
+// Go types of the values readers emit.
+var (
+	String = reflect.TypeOf("")
+	Bytes  = reflect.TypeOf([]byte(nil))
+	Int64  = reflect.TypeOf(int64(0))
+	Bool   = reflect.TypeOf(false)
+)
+
+// Column describes a column of the rows a reader emits.
+type Column struct {
+	Name string
+	// Type is the Go type of the values as the reader emits them, before
+	// Gleam encodes the rows between steps.
+	Type reflect.Type
+	// Nullable columns can also hold nil.
+	Nullable bool
+}
+
+// Schema is the ordered list of columns of the rows a reader emits.
+type Schema []Column
+
+// Names returns the names of the columns, which is the header of the rows.
+func (s Schema) Names() []string {
+	names := make([]string, len(s))
+	for i, c := range s {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// Index returns the position of the column with the given name, or -1 if
+// there is no such column.
+func (s Schema) Index(name string) int {
+	for i, c := range s {
+		if c.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Validate checks that the row has as many values as columns in the schema
+// and that every value has the type of its column.
+func (s Schema) Validate(row *util.Row) error {
+	values := make([]interface{}, 0, len(row.K)+len(row.V))
+	values = append(values, row.K...)
+	values = append(values, row.V...)
+
+	if len(values) != len(s) {
+		return errors.Errorf("row has %d values but the schema has %d columns", len(values), len(s))
+	}
+
+	for i, c := range s {
+		v := values[i]
+		if v == nil {
+			if !c.Nullable {
+				return errors.Errorf("column %s is not nullable", c.Name)
+			}
+			continue
+		}
+		if t := reflect.TypeOf(v); t != c.Type {
+			return errors.Errorf("column %s should be %s but is %s", c.Name, c.Type, t)
+		}
+	}
+	return nil
+}
+
+var schemas = map[string]Schema{
+	"repositories": RepositoriesSchema,
+	"references":   ReferencesSchema,
+	"commits":      CommitsSchema,
+	"trees":        TreesSchema,
+	"blobs":        BlobsSchema,
+}
+
+// SchemaOf returns the schema of the rows read for the given data type,
+// one of repositories, references, commits, trees or blobs.
+func SchemaOf(dataType string) (Schema, error) {
+	s, ok := schemas[dataType]
+	if !ok {
+		return nil, errors.Errorf("unknown data type %q", dataType)
+	}
+	return s, nil
+}
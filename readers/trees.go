@@ -26,13 +26,23 @@ func NewTrees(r *git.Repository, path string, commitsIter object.CommitIter) (*T
 	}, nil
 }
 
+// TreesSchema is the schema of the rows read by Trees.
+var TreesSchema = Schema{
+	{Name: "repositoryID", Type: String},
+	{Name: "commitHash", Type: String},
+	{Name: "blobHash", Type: String},
+	{Name: "fileName", Type: String},
+	// isFile is false for the entries of directories and submodules, whose
+	// hashes are the ones of a tree and a commit.
+	{Name: "isFile", Type: Bool},
+}
+
+func (r *Trees) Schema() Schema {
+	return TreesSchema
+}
+
 func (r *Trees) ReadHeader() ([]string, error) {
-	return []string{
-		"repositoryID",
-		"commitHash",
-		"blobHash",
-		"fileName",
-	}, nil
+	return TreesSchema.Names(), nil
 }
 
 func (r *Trees) Read() (*util.Row, error) {
@@ -63,6 +73,7 @@ func (r *Trees) Read() (*util.Row, error) {
 		r.commitHash,
 		entry.Hash.String(),
 		name,
+		entry.Mode.IsFile(),
 	), nil
 }
 
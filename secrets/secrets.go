@@ -0,0 +1,153 @@
+// Package secrets finds credentials in files, like API keys, tokens and
+// private keys, with rules matching their format and, for the ones without
+// a recognizable format, how random they look.
+package secrets
+
+import (
+	"bytes"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Rule finds a kind of secret.
+type Rule struct {
+	// ID names the rule in the findings, like aws-access-key-id.
+	ID string
+	// Pattern matches the secret. If it has groups, the secret is the
+	// first one, and the rest of the match is its context, like the name
+	// of the variable holding it.
+	Pattern *regexp.Regexp
+	// MinEntropy is the Shannon entropy, in bits per character, below which
+	// matches are not considered secrets, like passwords set to
+	// placeholders. Zero does not check it.
+	MinEntropy float64
+	// Prefix is the number of characters starting every secret of the
+	// kind, like AKIA for AWS keys, which are kept when redacting them as
+	// they are not secret. Zero redacts the secrets whole.
+	Prefix int
+}
+
+// DefaultRules are the rules of the credentials of the most used services.
+var DefaultRules = []Rule{
+	{
+		ID:      "aws-access-key-id",
+		Pattern: regexp.MustCompile(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`),
+		Prefix:  4,
+	},
+	{
+		ID:         "aws-secret-access-key",
+		Pattern:    regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|key).{0,20}?['"]([0-9a-zA-Z/+]{40})['"]`),
+		MinEntropy: 4,
+	},
+	{
+		ID:      "github-token",
+		Pattern: regexp.MustCompile(`\b(gh[pousr]_[0-9A-Za-z]{36})\b`),
+		Prefix:  4,
+	},
+	{
+		ID:      "slack-token",
+		Pattern: regexp.MustCompile(`\b(xox[baprs]-[0-9A-Za-z-]{10,72})\b`),
+		Prefix:  5,
+	},
+	{
+		ID:      "google-api-key",
+		Pattern: regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})`),
+		Prefix:  4,
+	},
+	{
+		ID:      "stripe-secret-key",
+		Pattern: regexp.MustCompile(`\b((?:sk|rk)_live_[0-9A-Za-z]{24,})\b`),
+		Prefix:  8,
+	},
+	{
+		ID:      "private-key",
+		Pattern: regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`),
+	},
+	{
+		ID:         "jwt",
+		Pattern:    regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`),
+		MinEntropy: 4,
+	},
+	{
+		ID:         "generic-secret",
+		Pattern:    regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|token|api_?key)["']?\s*[:=]\s*["']([^"'\s]{8,})["']`),
+		MinEntropy: 3.5,
+	},
+}
+
+// Finding is a secret found.
+type Finding struct {
+	// RuleID is the ID of the rule finding it.
+	RuleID string
+	// Line is the number of the line where it is, starting at 1.
+	Line int
+	// Match is the secret, redacted, see Redact.
+	Match string
+}
+
+// Scan returns the secrets found in the content by the rules, in the order
+// of the lines they are in.
+func Scan(content []byte, rules []Rule) []Finding {
+	var findings []Finding
+	for n := 1; len(content) > 0; n++ {
+		var line []byte
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			line, content = content[:i], content[i+1:]
+		} else {
+			line, content = content, nil
+		}
+
+		for _, rule := range rules {
+			for _, m := range rule.Pattern.FindAllSubmatch(line, -1) {
+				secret := m[0]
+				if len(m) > 1 {
+					secret = m[1]
+				}
+				if rule.MinEntropy > 0 && Entropy(string(secret)) < rule.MinEntropy {
+					continue
+				}
+
+				findings = append(findings, Finding{
+					RuleID: rule.ID,
+					Line:   n,
+					Match:  Redact(string(secret), rule.Prefix),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// Entropy returns the Shannon entropy of the string in bits per character,
+// which is higher the more random it looks.
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	var n int
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+
+	var entropy float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// Redact hides the secret but its first prefix characters, which are the
+// same for every secret of its kind, like AKIA for AWS keys, see
+// Rule.Prefix. Secrets are hidden whole when there is no such prefix.
+func Redact(secret string, prefix int) string {
+	runes := []rune(secret)
+	if prefix <= 0 || len(runes) <= prefix {
+		return strings.Repeat("*", 8)
+	}
+	return string(runes[:prefix]) + strings.Repeat("*", 8)
+}
@@ -0,0 +1,74 @@
+package sink
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/eiso/go-engine/readers"
+)
+
+// CSV writes the rows of d to w as RFC 4180 CSV, with a first record
+// holding the names of the columns of the schema, if any. Values with
+// commas, quotes or line breaks, like commit messages and the content of
+// files, are quoted.
+//
+// Nulls are written as empty values. Values of the columns of bytes, like
+// the content of blobs, are always encoded with base64, and the others are
+// written as UTF-8 text.
+func CSV(d *flow.Dataset, w io.Writer, schema readers.Schema) *flow.Dataset {
+	var (
+		mu     sync.Mutex
+		header sync.Once
+		out    = csv.NewWriter(w)
+	)
+	out.UseCRLF = true
+
+	return d.Output(func(r io.Reader) error {
+		var err error
+		header.Do(func() {
+			if len(schema) == 0 {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			out.Write(schema.Names())
+			out.Flush()
+			err = out.Error()
+		})
+		if err != nil {
+			return err
+		}
+
+		return readRows(r, func(values []interface{}) error {
+			record := make([]string, len(values))
+			for i, v := range values {
+				record[i] = csvValue(v, isBytes(schema, i))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			out.Write(record)
+			out.Flush()
+			return out.Error()
+		})
+	})
+}
+
+func csvValue(v interface{}, bytes bool) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return text(v, bytes)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,79 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/eiso/go-engine/readers"
+	"github.com/pkg/errors"
+)
+
+// JSONL writes the rows of d to w as JSON Lines, one object per row with
+// the values keyed by the names of the columns of the schema, usually the
+// one of a dataset.Dataset, see its Schema method, or Untyped. Values of columns past the ones of the
+// schema are keyed by their position, like "_3".
+//
+// Values of the columns of bytes, like the content of blobs, are always
+// strings encoded with base64, and the others are written as UTF-8 text.
+func JSONL(d *flow.Dataset, w io.Writer, schema readers.Schema) *flow.Dataset {
+	var mu sync.Mutex
+	return d.Output(func(r io.Reader) error {
+		var buf bytes.Buffer
+		return readRows(r, func(values []interface{}) error {
+			buf.Reset()
+			if err := encodeObject(&buf, schema, values); err != nil {
+				return err
+			}
+			buf.WriteByte('\n')
+
+			mu.Lock()
+			defer mu.Unlock()
+			_, err := w.Write(buf.Bytes())
+			return err
+		})
+	})
+}
+
+// encodeObject writes the row as a JSON object keeping the order of the
+// columns, which a map would not.
+func encodeObject(buf *bytes.Buffer, schema readers.Schema, values []interface{}) error {
+	buf.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(columnName(schema, i))
+		if err != nil {
+			return err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+
+		encoded, err := json.Marshal(jsonValue(v, isBytes(schema, i)))
+		if err != nil {
+			return errors.Wrapf(err, "could not encode column %s", columnName(schema, i))
+		}
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// jsonValue converts the bytes found in the value to strings, see text,
+// since encoding/json encodes them with base64 even when they are text.
+func jsonValue(v interface{}, bytes bool) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return text(v, bytes)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			values[i] = jsonValue(e, bytes)
+		}
+		return values
+	}
+	return v
+}
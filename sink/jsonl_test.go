@@ -0,0 +1,44 @@
+package sink
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/eiso/go-engine/readers"
+)
+
+func TestEncodeObject(t *testing.T) {
+	schema := readers.Schema{
+		{Name: "fileName", Type: readers.String},
+		{Name: "content", Type: readers.Bytes},
+		{Name: "size", Type: readers.Int64},
+	}
+
+	cases := []struct {
+		values   []interface{}
+		expected string
+	}{
+		{
+			[]interface{}{[]byte("a.txt"), []byte("aGVsbG8="), int64(8)},
+			`{"fileName":"a.txt","content":"YUdWc2JHOD0=","size":8}`,
+		},
+		{
+			[]interface{}{[]byte("b.png"), []byte{0x89, 'P', 'N', 'G'}, int64(4)},
+			`{"fileName":"b.png","content":"iVBORw==","size":4}`,
+		},
+		{
+			[]interface{}{[]byte("c\xff"), nil, int64(0), []byte("extra")},
+			`{"fileName":"c` + "�" + `","content":null,"size":0,"_4":"extra"}`,
+		},
+	}
+
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := encodeObject(&buf, schema, c.values); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.expected {
+			t.Errorf("expected %s, got %s", c.expected, buf.String())
+		}
+	}
+}
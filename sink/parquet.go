@@ -0,0 +1,386 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/util"
+	"github.com/eiso/go-engine/readers"
+	"github.com/pkg/errors"
+)
+
+// rowGroupSize is the approximate size of the values buffered before
+// writing them as a row group.
+const rowGroupSize = 64 << 20
+
+// physical types of Parquet
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+)
+
+// Untyped returns a schema of nullable columns with the given names, whose
+// types are found from the values written.
+func Untyped(names ...string) readers.Schema {
+	schema := make(readers.Schema, len(names))
+	for i, name := range names {
+		schema[i] = readers.Column{Name: name, Nullable: true}
+	}
+	return schema
+}
+
+// Parquet writes the rows of d to dir, one Parquet file per partition of d
+// named after its index, part-00000.parquet for the first partition,
+// part-00001.parquet for the second one and so on. The columns of the files
+// are the ones of the schema, usually the one of a dataset.Dataset, see its
+// Schema method. Strings are written as UTF-8 byte arrays and bytes,
+// like the content of blobs, as plain byte arrays. Columns without a type
+// take the type of their first value that is not null in every file, or are
+// byte arrays when there is none.
+//
+// Like all the outputs of Gleam, the files are written where the flow is
+// run from.
+func Parquet(d *flow.Dataset, dir string, schema readers.Schema) *flow.Dataset {
+	return outputPartitions(d, func(partition int, r io.Reader) error {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.Wrapf(err, "could not create %s", dir)
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("part-%05d.parquet", partition))
+		f, err := os.Create(path)
+		if err != nil {
+			return errors.Wrapf(err, "could not create %s", path)
+		}
+
+		w := newParquetWriter(f, schema)
+		err = readRows(r, w.Write)
+		if err == nil {
+			err = w.Close()
+		}
+
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return errors.Wrapf(err, "could not write %s", path)
+	})
+}
+
+type parquetColumn struct {
+	readers.Column
+	// typ is the physical type, or -1 until it is known.
+	typ  int32
+	utf8 bool
+}
+
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+type rowGroup struct {
+	columns []columnChunk
+	rows    int64
+	size    int64
+}
+
+// parquetWriter writes rows to a Parquet file, with a single uncompressed
+// data page of plainly encoded values per column of every row group.
+type parquetWriter struct {
+	w       *bufio.Writer
+	offset  int64
+	columns []parquetColumn
+
+	rows   [][]interface{}
+	size   int
+	groups []rowGroup
+	err    error
+}
+
+func newParquetWriter(w io.Writer, schema readers.Schema) *parquetWriter {
+	columns := make([]parquetColumn, len(schema))
+	for i, c := range schema {
+		columns[i] = parquetColumn{Column: c, typ: -1}
+		if c.Type != nil {
+			columns[i].typ, columns[i].utf8 = parquetType(c.Type)
+		}
+	}
+
+	pw := &parquetWriter{w: bufio.NewWriter(w), columns: columns}
+	pw.write([]byte("PAR1"))
+	return pw
+}
+
+// parquetType returns the physical type of the values of type t, and
+// whether they are strings.
+func parquetType(t reflect.Type) (int32, bool) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return parquetBoolean, false
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return parquetInt64, false
+	case reflect.Float32, reflect.Float64:
+		return parquetDouble, false
+	case reflect.String:
+		return parquetByteArray, true
+	}
+	return parquetByteArray, false
+}
+
+func (w *parquetWriter) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.Write(p)
+	w.offset += int64(len(p))
+}
+
+// Write buffers a row, writing a row group once there are enough of them.
+func (w *parquetWriter) Write(row []interface{}) error {
+	if len(row) != len(w.columns) {
+		return errors.Errorf("row has %d values but the schema has %d columns", len(row), len(w.columns))
+	}
+
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			if !w.columns[i].Nullable {
+				return errors.Errorf("column %s is not nullable", w.columns[i].Name)
+			}
+		case string:
+			w.size += len(v)
+		case []byte:
+			w.size += len(v)
+		default:
+			w.size += 8
+		}
+	}
+
+	w.rows = append(w.rows, row)
+	if w.size >= rowGroupSize {
+		w.flush()
+	}
+	return w.err
+}
+
+// flush writes the buffered rows as a row group.
+func (w *parquetWriter) flush() {
+	if len(w.rows) == 0 {
+		return
+	}
+
+	group := rowGroup{rows: int64(len(w.rows))}
+	for i := range w.columns {
+		// the pages of columns only holding nulls have no values, so
+		// their type can still be found in the next row groups
+		c := &w.columns[i]
+		if c.typ < 0 {
+			for _, row := range w.rows {
+				if row[i] != nil {
+					c.typ, c.utf8 = parquetType(reflect.TypeOf(row[i]))
+					break
+				}
+			}
+		}
+
+		chunk := w.writeColumn(c, i)
+		group.columns = append(group.columns, chunk)
+		group.size += chunk.size
+	}
+
+	w.groups = append(w.groups, group)
+	w.rows = nil
+	w.size = 0
+}
+
+// writeColumn writes the values of the column i of the buffered rows as a
+// data page.
+func (w *parquetWriter) writeColumn(c *parquetColumn, i int) columnChunk {
+	var page []byte
+	if c.Nullable {
+		levels := make([]bool, len(w.rows))
+		for j, row := range w.rows {
+			levels[j] = row[i] != nil
+		}
+		encoded := encodeLevels(levels)
+		page = appendUint32(page, uint32(len(encoded)))
+		page = append(page, encoded...)
+	}
+
+	var bits []bool
+	for _, row := range w.rows {
+		v := row[i]
+		if v == nil {
+			continue
+		}
+
+		switch c.typ {
+		case parquetBoolean:
+			b, _ := v.(bool)
+			bits = append(bits, b)
+		case parquetInt64:
+			page = appendUint64(page, uint64(util.ToInt64(v)))
+		case parquetDouble:
+			page = appendUint64(page, math.Float64bits(util.ToFloat64(v)))
+		default:
+			b := util.ToBytes(v)
+			page = appendUint32(page, uint32(len(b)))
+			page = append(page, b...)
+		}
+	}
+	if c.typ == parquetBoolean {
+		page = append(page, packBits(bits)...)
+	}
+
+	header := newThriftWriter()
+	header.I32(1, 0) // data page
+	header.I32(2, int32(len(page)))
+	header.I32(3, int32(len(page)))
+	header.Struct(5)
+	header.I32(1, int32(len(w.rows)))
+	header.I32(2, 0) // plain values
+	header.I32(3, 3) // RLE definition levels
+	header.I32(4, 3) // RLE repetition levels
+	header.End()
+	header.End()
+
+	chunk := columnChunk{offset: w.offset}
+	w.write(header.Bytes())
+	w.write(page)
+	chunk.size = w.offset - chunk.offset
+	return chunk
+}
+
+// encodeLevels encodes definition levels of bit width 1 as RLE runs.
+func encodeLevels(levels []bool) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+
+		var header [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(header[:], uint64(j-i)<<1)
+		out = append(out, header[:n]...)
+		if levels[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// packBits packs booleans in bytes, starting from the least significant
+// bit.
+func packBits(bits []bool) []byte {
+	out := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			out[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return out
+}
+
+// Close writes the rows left and the footer of the file.
+func (w *parquetWriter) Close() error {
+	w.flush()
+
+	for i := range w.columns {
+		if w.columns[i].typ < 0 {
+			w.columns[i].typ = parquetByteArray
+		}
+	}
+
+	var rows int64
+	for _, g := range w.groups {
+		rows += g.rows
+	}
+
+	meta := newThriftWriter()
+	meta.I32(1, 1)
+	meta.List(2, thriftStruct, len(w.columns)+1)
+	meta.Struct(0)
+	meta.String(4, "schema")
+	meta.I32(5, int32(len(w.columns)))
+	meta.End()
+	for _, c := range w.columns {
+		meta.Struct(0)
+		meta.I32(1, c.typ)
+		if c.Nullable {
+			meta.I32(3, 1) // optional
+		} else {
+			meta.I32(3, 0) // required
+		}
+		meta.String(4, c.Name)
+		if c.utf8 {
+			meta.I32(6, 0)
+		}
+		meta.End()
+	}
+	meta.I64(3, rows)
+
+	meta.List(4, thriftStruct, len(w.groups))
+	for _, g := range w.groups {
+		meta.Struct(0)
+		meta.List(1, thriftStruct, len(g.columns))
+		for i, chunk := range g.columns {
+			c := w.columns[i]
+			meta.Struct(0)
+			meta.I64(2, chunk.offset)
+			meta.Struct(3)
+			meta.I32(1, c.typ)
+			meta.List(2, thriftI32, 2)
+			meta.ListI32(0) // plain
+			meta.ListI32(3) // RLE
+			meta.List(3, thriftBinary, 1)
+			meta.ListString(c.Name)
+			meta.I32(4, 0) // uncompressed
+			meta.I64(5, g.rows)
+			meta.I64(6, chunk.size)
+			meta.I64(7, chunk.size)
+			meta.I64(9, chunk.offset)
+			meta.End()
+			meta.End()
+		}
+		meta.I64(2, g.size)
+		meta.I64(3, g.rows)
+		meta.End()
+	}
+	meta.String(6, "go-engine")
+	meta.End()
+
+	footer := meta.Bytes()
+	w.write(footer)
+	w.write(appendUint32(nil, uint32(len(footer))))
+	w.write([]byte("PAR1"))
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
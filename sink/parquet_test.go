@@ -0,0 +1,330 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	"github.com/eiso/go-engine/readers"
+)
+
+func TestMain(m *testing.M) {
+	gio.Init()
+	os.Exit(m.Run())
+}
+
+// The files are read back following the Parquet format specification,
+// https://github.com/apache/parquet-format, independently of the writer.
+
+// thriftReader decodes thrift compact protocol structs into their fields
+// by id.
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.b[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		panic("invalid varint")
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var id int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return fields
+		}
+		if delta := int16(h >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(h & 0x0f)
+	}
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		return int64(r.byte())
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.pos:]))
+		r.pos += 8
+		return v
+	case 8:
+		n := int(r.varint())
+		b := r.b[r.pos : r.pos+n]
+		r.pos += n
+		return b
+	case 9, 10:
+		h := r.byte()
+		size := int(h >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			if t := h & 0x0f; t == 1 || t == 2 {
+				list[i] = r.byte() == 1
+			} else {
+				list[i] = r.value(t)
+			}
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unsupported thrift type %d", typ))
+}
+
+type parquetFile struct {
+	names    []string
+	types    []int64
+	optional []bool
+	utf8     []bool
+	rows     int64
+	groups   int
+	values   [][]interface{}
+}
+
+// readParquet decodes a file of plain encoded, uncompressed data pages.
+func readParquet(t *testing.T, b []byte) *parquetFile {
+	if string(b[:4]) != "PAR1" || string(b[len(b)-4:]) != "PAR1" {
+		t.Fatal("missing magic number")
+	}
+	size := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	meta := (&thriftReader{b: b[len(b)-8-size : len(b)-8]}).readStruct()
+
+	file := &parquetFile{rows: meta[3].(int64)}
+	for _, e := range meta[2].([]interface{})[1:] {
+		element := e.(map[int16]interface{})
+		file.names = append(file.names, string(element[4].([]byte)))
+		file.types = append(file.types, element[1].(int64))
+		file.optional = append(file.optional, element[3].(int64) == 1)
+		_, utf8 := element[6]
+		file.utf8 = append(file.utf8, utf8)
+	}
+
+	for _, g := range meta[4].([]interface{}) {
+		group := g.(map[int16]interface{})
+		file.groups++
+		rows := make([][]interface{}, group[3].(int64))
+		for i := range rows {
+			rows[i] = make([]interface{}, len(file.names))
+		}
+
+		for i, c := range group[1].([]interface{}) {
+			chunk := c.(map[int16]interface{})[3].(map[int16]interface{})
+			if chunk[1].(int64) != file.types[i] {
+				t.Errorf("column %s has type %d in a row group and %d in the schema",
+					file.names[i], chunk[1], file.types[i])
+			}
+			readPage(t, b[chunk[9].(int64):], file.types[i], file.optional[i], rows, i)
+		}
+		file.values = append(file.values, rows...)
+	}
+	return file
+}
+
+func readPage(t *testing.T, b []byte, typ int64, optional bool, rows [][]interface{}, column int) {
+	r := &thriftReader{b: b}
+	header := r.readStruct()
+	if header[1].(int64) != 0 {
+		t.Fatalf("page of type %d instead of a data page", header[1])
+	}
+	page := b[r.pos : r.pos+int(header[3].(int64))]
+
+	defined := make([]bool, len(rows))
+	for i := range defined {
+		defined[i] = true
+	}
+	if optional {
+		n := int(binary.LittleEndian.Uint32(page))
+		defined = decodeLevels(page[4:4+n], len(rows))
+		page = page[4+n:]
+	}
+
+	bit := 0
+	for i := range rows {
+		if !defined[i] {
+			continue
+		}
+		switch typ {
+		case parquetBoolean:
+			rows[i][column] = page[bit/8]&(1<<uint(bit%8)) != 0
+			bit++
+		case parquetInt64:
+			rows[i][column] = int64(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+		case parquetDouble:
+			rows[i][column] = math.Float64frombits(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+		case parquetByteArray:
+			n := int(binary.LittleEndian.Uint32(page))
+			rows[i][column] = page[4 : 4+n]
+			page = page[4+n:]
+		default:
+			t.Fatalf("unexpected type %d", typ)
+		}
+	}
+}
+
+// decodeLevels decodes n levels of bit width 1 of the RLE and bit packing
+// hybrid encoding.
+func decodeLevels(b []byte, n int) []bool {
+	var levels []bool
+	for len(levels) < n {
+		h, size := binary.Uvarint(b)
+		b = b[size:]
+		if h&1 == 0 {
+			for i := uint64(0); i < h>>1; i++ {
+				levels = append(levels, b[0] == 1)
+			}
+			b = b[1:]
+			continue
+		}
+		for i := 0; i < int(h>>1)*8; i++ {
+			levels = append(levels, b[i/8]&(1<<uint(i%8)) != 0)
+		}
+		b = b[h>>1:]
+	}
+	return levels[:n]
+}
+
+func writeParquet(t *testing.T, schema readers.Schema, groups ...[][]interface{}) []byte {
+	var buf bytes.Buffer
+	w := newParquetWriter(&buf, schema)
+	for _, rows := range groups {
+		for _, row := range rows {
+			if err := w.Write(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w.flush()
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	schema := readers.Schema{
+		{Name: "name", Type: reflect.TypeOf("")},
+		{Name: "content", Type: reflect.TypeOf([]byte(nil)), Nullable: true},
+		{Name: "size", Type: reflect.TypeOf(int64(0))},
+		{Name: "binary", Type: reflect.TypeOf(false)},
+		{Name: "ratio", Type: reflect.TypeOf(0.0), Nullable: true},
+	}
+	rows := [][]interface{}{
+		{"a.go", []byte("package a\n"), int64(10), false, 0.5},
+		{"b.png", []byte{0x89, 'P', 'N', 'G', 0xff}, int64(5), true, nil},
+		{"empty", nil, int64(0), false, nil},
+		{"c.go", []byte("package c\n"), int64(-1), true, 2.0},
+	}
+
+	file := readParquet(t, writeParquet(t, schema, rows[:3], rows[3:]))
+
+	if !reflect.DeepEqual(file.names, schema.Names()) {
+		t.Errorf("expected columns %v, got %v", schema.Names(), file.names)
+	}
+	expectedTypes := []int64{parquetByteArray, parquetByteArray, parquetInt64, parquetBoolean, parquetDouble}
+	if !reflect.DeepEqual(file.types, expectedTypes) {
+		t.Errorf("expected types %v, got %v", expectedTypes, file.types)
+	}
+	if !reflect.DeepEqual(file.utf8, []bool{true, false, false, false, false}) {
+		t.Errorf("expected only the strings to be UTF-8, got %v", file.utf8)
+	}
+	if !reflect.DeepEqual(file.optional, []bool{false, true, false, false, true}) {
+		t.Errorf("expected the nullable columns to be optional, got %v", file.optional)
+	}
+	if file.rows != 4 || file.groups != 2 {
+		t.Errorf("expected 4 rows in 2 row groups, got %d rows in %d", file.rows, file.groups)
+	}
+
+	for i, row := range rows {
+		row[0] = []byte(row[0].(string))
+		if !reflect.DeepEqual(file.values[i], row) {
+			t.Errorf("expected row %d to be %v, got %v", i, row, file.values[i])
+		}
+	}
+}
+
+func TestParquetTypesOfLaterRowGroups(t *testing.T) {
+	file := readParquet(t, writeParquet(t, Untyped("name", "size"),
+		[][]interface{}{{"a", nil}, {"b", nil}},
+		[][]interface{}{{"c", int64(3)}},
+	))
+
+	if file.types[1] != parquetInt64 {
+		t.Errorf("expected the column first holding nulls to be INT64, got %d", file.types[1])
+	}
+	expected := []interface{}{nil, nil, int64(3)}
+	for i, row := range file.values {
+		if !reflect.DeepEqual(row[1], expected[i]) {
+			t.Errorf("expected size %v in row %d, got %v", expected[i], i, row[1])
+		}
+	}
+}
+
+func TestParquetPartitionFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// integer keys are partitioned by their value
+	f := flow.New("parquet")
+	Parquet(f.Ints([]int{0, 1, 2, 3, 4, 5, 6, 7, 8}).PartitionByKey("partition", 3), dir, Untyped("n"))
+	f.Run()
+
+	for partition := 0; partition < 3; partition++ {
+		b, err := ioutil.ReadFile(filepath.Join(dir, fmt.Sprintf("part-%05d.parquet", partition)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var values []int
+		for _, row := range readParquet(t, b).values {
+			values = append(values, int(row[0].(int64)))
+		}
+		sort.Ints(values)
+		expected := []int{partition, partition + 3, partition + 6}
+		if !reflect.DeepEqual(values, expected) {
+			t.Errorf("expected %v in partition %d, got %v", expected, partition, values)
+		}
+	}
+}
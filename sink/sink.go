@@ -0,0 +1,81 @@
+// Package sink writes the rows of Gleam datasets to files.
+package sink
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/pb"
+	"github.com/chrislusf/gleam/util"
+	"github.com/eiso/go-engine/readers"
+)
+
+// outputPartitions is like flow.Dataset.Output but tells fn the index of
+// the partition of d it reads, starting at 0.
+func outputPartitions(d *flow.Dataset, fn func(partition int, r io.Reader) error) *flow.Dataset {
+	step := d.Flow.AddAllToOneStep(d, nil)
+	step.IsOnDriverSide = true
+	step.Name = "Output"
+	step.Function = func(readers []io.Reader, writers []io.Writer, stat *pb.InstructionStat) error {
+		errs := make(chan error, len(readers))
+		for i, r := range readers {
+			go func(i int, r io.Reader) {
+				errs <- fn(i, r)
+			}(i, r)
+		}
+		for range readers {
+			if err := <-errs; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return d
+}
+
+// readRows decodes the rows Gleam outputs to r, passing the values of each
+// of them to fn.
+func readRows(r io.Reader, fn func(values []interface{}) error) error {
+	return util.TakeMessage(r, -1, func(encoded []byte) error {
+		row, err := util.DecodeRow(encoded)
+		if err != nil {
+			return err
+		}
+
+		values := append(row.K, row.V...)
+		// Gleam groups empty partitions into a single empty row
+		if len(values) == 0 {
+			return nil
+		}
+		return fn(values)
+	})
+}
+
+// columnName returns the name of the column i, or its position, starting
+// at 1, if there are not so many columns.
+func columnName(schema readers.Schema, i int) string {
+	if i < len(schema) {
+		return schema[i].Name
+	}
+	return fmt.Sprintf("_%d", i+1)
+}
+
+// isBytes reports whether the column i of the schema holds bytes, like the
+// content of blobs, rather than text.
+func isBytes(schema readers.Schema, i int) bool {
+	return i < len(schema) && schema[i].Type == readers.Bytes
+}
+
+// text returns the bytes of a value as a string: encoded with base64 if
+// they are the value of a bytes column, see isBytes, or as UTF-8 text
+// otherwise, since Gleam decodes strings as bytes, with the invalid bytes
+// replaced by U+FFFD. Whether a value is base64 only depends on its column.
+func text(b []byte, bytes bool) string {
+	if bytes {
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return strings.ToValidUTF8(string(b), "\uFFFD")
+}
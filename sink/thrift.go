@@ -0,0 +1,108 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// types of the thrift compact protocol
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the thrift structures of the Parquet metadata with
+// the compact protocol, the only one Parquet uses.
+type thriftWriter struct {
+	buf bytes.Buffer
+	// last holds the id of the last field written of every open struct.
+	last []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func (w *thriftWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) I32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) I64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) String(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.varint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+// List starts a list field of size elements of the given type, which are
+// written right after with the methods without field id.
+func (w *thriftWriter) List(id int16, typ byte, size int) {
+	w.field(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | typ)
+		return
+	}
+	w.buf.WriteByte(0xf0 | typ)
+	w.varint(uint64(size))
+}
+
+// Struct starts a struct field, or a struct element of a list if id is 0.
+// Its fields are written until End.
+func (w *thriftWriter) Struct(id int16) {
+	if id != 0 {
+		w.field(id, thriftStruct)
+	}
+	w.last = append(w.last, 0)
+}
+
+// End ends the last struct started, or the top level one.
+func (w *thriftWriter) End() {
+	w.buf.WriteByte(0)
+	if len(w.last) > 1 {
+		w.last = w.last[:len(w.last)-1]
+	}
+}
+
+// ListI32 writes an element of a list of integers.
+func (w *thriftWriter) ListI32(v int32) {
+	w.zigzag(int64(v))
+}
+
+// ListString writes an element of a list of strings.
+func (w *thriftWriter) ListString(s string) {
+	w.varint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
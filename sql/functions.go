@@ -0,0 +1,166 @@
+package sql
+
+import (
+	"strings"
+
+	"github.com/chrislusf/gleam/gio"
+	"github.com/chrislusf/gleam/util"
+	"github.com/pkg/errors"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+// Gleam runs mappers and reducers in other processes, which only know the
+// name they were registered with. Since they can't receive the parameters
+// of a query, they all work on the last columns of the rows, and the
+// planner moves the columns they need there with a projection first.
+
+// Function is a scalar function usable in queries. It receives the values
+// of its arguments and returns the value of the call.
+type Function func(args ...interface{}) (interface{}, error)
+
+type function struct {
+	args   int
+	mapper gio.MapperId
+}
+
+var functions = map[string]function{}
+
+// RegisterFunction makes a function taking args arguments callable from
+// queries by its name, which is case insensitive. Like gio.RegisterMapper,
+// it must be called when the program starts, usually from an init function.
+func RegisterFunction(name string, args int, fn Function) {
+	functions[strings.ToUpper(name)] = function{
+		args: args,
+		mapper: gio.RegisterMapper(func(x []interface{}) error {
+			if len(x) < args {
+				return nil
+			}
+
+			n := len(x) - args
+			v, err := fn(x[n:]...)
+			if err != nil {
+				return err
+			}
+			return gio.Emit(append(x[:n:n], v)...)
+		}),
+	}
+}
+
+func init() {
+	RegisterFunction("LANGUAGE", 2, func(args ...interface{}) (interface{}, error) {
+		return enry.GetLanguage(util.ToString(args[0]), util.ToBytes(args[1])), nil
+	})
+	RegisterFunction("LOWER", 1, func(args ...interface{}) (interface{}, error) {
+		return strings.ToLower(util.ToString(args[0])), nil
+	})
+	RegisterFunction("UPPER", 1, func(args ...interface{}) (interface{}, error) {
+		return strings.ToUpper(util.ToString(args[0])), nil
+	})
+}
+
+// prependKey adds a constant first column, to aggregate all the rows as a
+// single group.
+var prependKey = gio.RegisterMapper(func(x []interface{}) error {
+	if len(x) == 0 {
+		return nil
+	}
+	return gio.Emit(append([]interface{}{int64(0)}, x...)...)
+})
+
+// Aggregations don't use reducers, which Gleam runs through a shell along
+// with the arguments of the program, but mappers of the rows grouped by the
+// keys. Their rows have the keys followed by the grouped rows, made of the
+// aggregated value.
+
+// aggregates are the mappers of the aggregation functions.
+var aggregates = map[string]gio.MapperId{
+	"COUNT": registerAggregate(func(acc, v interface{}) (interface{}, error) {
+		if v == nil {
+			return acc, nil
+		}
+		return acc.(int64) + 1, nil
+	}, int64(0)),
+	"SUM": registerAggregate(sum, nil),
+	"MIN": registerAggregate(func(acc, v interface{}) (interface{}, error) {
+		if acc == nil || (v != nil && util.Compare(v, acc) < 0) {
+			return v, nil
+		}
+		return acc, nil
+	}, nil),
+	"MAX": registerAggregate(func(acc, v interface{}) (interface{}, error) {
+		if acc == nil || (v != nil && util.Compare(v, acc) > 0) {
+			return v, nil
+		}
+		return acc, nil
+	}, nil),
+}
+
+// countAll is the mapper of COUNT(*), which counts the grouped rows.
+var countAll = gio.RegisterMapper(func(x []interface{}) error {
+	keys, group := splitGroup(x)
+	if keys == nil {
+		return nil
+	}
+	return gio.Emit(append(keys, int64(len(group)))...)
+})
+
+// registerAggregate registers a mapper folding the values of every group
+// with fn, starting from init.
+func registerAggregate(fn func(acc, v interface{}) (interface{}, error), init interface{}) gio.MapperId {
+	return gio.RegisterMapper(func(x []interface{}) error {
+		keys, group := splitGroup(x)
+		if keys == nil {
+			return nil
+		}
+
+		acc := init
+		for _, row := range group {
+			var v interface{}
+			if values, ok := row.([]interface{}); ok && len(values) > 0 {
+				v = values[0]
+			}
+
+			var err error
+			if acc, err = fn(acc, v); err != nil {
+				return err
+			}
+		}
+		return gio.Emit(append(keys, acc)...)
+	})
+}
+
+// splitGroup splits a grouped row into its keys and the grouped rows, which
+// are the only lists in it.
+func splitGroup(x []interface{}) ([]interface{}, []interface{}) {
+	for i, v := range x {
+		if _, ok := v.([]interface{}); ok {
+			return x[:i:i], x[i:]
+		}
+	}
+	return nil, nil
+}
+
+func sum(x, y interface{}) (interface{}, error) {
+	switch {
+	case x == nil:
+		return y, nil
+	case y == nil:
+		return x, nil
+	}
+
+	switch x.(type) {
+	case string, []byte, bool:
+		return nil, errors.Errorf("can't sum %v", x)
+	}
+	switch y.(type) {
+	case string, []byte, bool:
+		return nil, errors.Errorf("can't sum %v", y)
+	}
+
+	_, xf := x.(float64)
+	_, yf := y.(float64)
+	if xf || yf {
+		return util.ToFloat64(x) + util.ToFloat64(y), nil
+	}
+	return util.ToInt64(x) + util.ToInt64(y), nil
+}
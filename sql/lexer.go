@@ -0,0 +1,117 @@
+package sql
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenKeyword
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string
+	pos   int
+}
+
+var keywords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "JOIN": true, "INNER": true,
+	"ON": true, "AND": true, "OR": true, "NOT": true, "IN": true, "LIKE": true,
+	"GROUP": true, "ORDER": true, "BY": true, "ASC": true, "DESC": true,
+	"LIMIT": true, "AS": true, "TRUE": true, "FALSE": true, "NULL": true,
+}
+
+// lex splits a query into tokens. Keywords are upper cased, identifiers
+// keep their case and can be quoted with double quotes or backticks.
+func lex(query string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(query); {
+		c := rune(query[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(query) && isIdentChar(rune(query[i])) {
+				i++
+			}
+			word := query[start:i]
+			if keywords[strings.ToUpper(word)] {
+				tokens = append(tokens, token{kind: tokenKeyword, text: strings.ToUpper(word), pos: start})
+			} else {
+				tokens = append(tokens, token{kind: tokenIdent, text: word, value: word, pos: start})
+			}
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(query) && unicode.IsDigit(rune(query[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: query[start:i], value: query[start:i], pos: start})
+		case c == '\'' || c == '"' || c == '`':
+			start := i
+			value, n, err := readQuoted(query[i:])
+			if err != nil {
+				return nil, errors.Wrapf(err, "at position %d", start)
+			}
+			i += n
+			kind := tokenIdent
+			if c == '\'' {
+				kind = tokenString
+			}
+			tokens = append(tokens, token{kind: kind, text: query[start:i], value: value, pos: start})
+		default:
+			start := i
+			symbol := string(c)
+			for _, s := range []string{"<=", ">=", "<>", "!="} {
+				if strings.HasPrefix(query[i:], s) {
+					symbol = s
+				}
+			}
+			if !strings.Contains("(),.*;=<>!", symbol[:1]) || symbol == "!" {
+				return nil, errors.Errorf("unexpected character %q at position %d", c, i)
+			}
+			i += len(symbol)
+			tokens = append(tokens, token{kind: tokenSymbol, text: symbol, pos: start})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(query)}), nil
+}
+
+func isIdentChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// readQuoted reads a quoted string, where the quote is escaped by doubling
+// it, returning its value and the number of bytes read.
+func readQuoted(s string) (string, int, error) {
+	quote := s[0]
+	var value []byte
+	for i := 1; i < len(s); i++ {
+		if s[i] != quote {
+			value = append(value, s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == quote {
+			value = append(value, quote)
+			i++
+			continue
+		}
+		return string(value), i + 1, nil
+	}
+	return "", 0, errors.New("unterminated quoted string")
+}
@@ -0,0 +1,459 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Query is a parsed SELECT statement.
+type Query struct {
+	// Select is empty when all the columns are selected with *.
+	Select  []SelectItem
+	From    Table
+	Joins   []Join
+	Where   []Predicate
+	GroupBy []Expr
+	OrderBy []OrderItem
+	// Limit is the maximum number of rows, or -1 when there is no limit.
+	Limit int
+}
+
+// Table is a table in the FROM clause.
+type Table struct {
+	Name  string
+	Alias string
+}
+
+// Join is a table joined to the previous ones by the equalities in On.
+type Join struct {
+	Table Table
+	On    []Predicate
+}
+
+// SelectItem is an expression of the SELECT clause.
+type SelectItem struct {
+	Expr  Expr
+	Alias string
+}
+
+// OrderItem is an expression of the ORDER BY clause, or the position of a
+// selected expression, starting at 1.
+type OrderItem struct {
+	Expr     Expr
+	Position int
+	Desc     bool
+}
+
+// Predicate compares an expression with another one or, for IN and NOT IN,
+// with a list of values.
+type Predicate struct {
+	Left   Expr
+	Op     string
+	Right  Expr
+	Values []Expr
+}
+
+// Expr is a column, a function call or a literal.
+type Expr interface {
+	String() string
+}
+
+// Column is a reference to a column, optionally qualified by its table.
+type Column struct {
+	Table string
+	Name  string
+}
+
+func (c *Column) String() string {
+	if c.Table == "" {
+		return c.Name
+	}
+	return c.Table + "." + c.Name
+}
+
+// Call is a function call. Star is set for COUNT(*).
+type Call struct {
+	Name string
+	Args []Expr
+	Star bool
+}
+
+func (c *Call) String() string {
+	if c.Star {
+		return c.Name + "(*)"
+	}
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = a.String()
+	}
+	return c.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Literal is a string, integer or boolean value, or nil for NULL.
+type Literal struct {
+	Value interface{}
+}
+
+func (l *Literal) String() string {
+	switch v := l.Value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v))
+	}
+	return fmt.Sprint(l.Value)
+}
+
+// Parse parses a SELECT statement.
+func Parse(query string) (*Query, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	q, err := p.query()
+	if err != nil {
+		tok := p.peek()
+		return nil, errors.Wrapf(err, "syntax error at position %d", tok.pos)
+	}
+	return q, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is the given keyword or symbol.
+func (p *parser) accept(text string) bool {
+	tok := p.peek()
+	if (tok.kind == tokenKeyword || tok.kind == tokenSymbol) && tok.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text ...string) error {
+	for _, t := range text {
+		if !p.accept(t) {
+			return errors.Errorf("expected %s but found %s", t, describe(p.peek()))
+		}
+	}
+	return nil
+}
+
+func describe(tok token) string {
+	if tok.kind == tokenEOF {
+		return "end of query"
+	}
+	return strconv.Quote(tok.text)
+}
+
+func (p *parser) query() (*Query, error) {
+	q := &Query{Limit: -1}
+	if err := p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+
+	if !p.accept("*") {
+		for {
+			item, err := p.selectItem()
+			if err != nil {
+				return nil, err
+			}
+			q.Select = append(q.Select, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+
+	if err := p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	var err error
+	if q.From, err = p.table(); err != nil {
+		return nil, err
+	}
+
+	for {
+		p.accept("INNER")
+		if !p.accept("JOIN") {
+			break
+		}
+		var j Join
+		if j.Table, err = p.table(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("ON"); err != nil {
+			return nil, err
+		}
+		if j.On, err = p.conjunction(); err != nil {
+			return nil, err
+		}
+		q.Joins = append(q.Joins, j)
+	}
+
+	if p.accept("WHERE") {
+		if q.Where, err = p.conjunction(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.accept("GROUP") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			q.GroupBy = append(q.GroupBy, e)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+
+	if p.accept("ORDER") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			item, err := p.orderItem()
+			if err != nil {
+				return nil, err
+			}
+			q.OrderBy = append(q.OrderBy, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+
+	if p.accept("LIMIT") {
+		tok := p.next()
+		if tok.kind != tokenNumber {
+			return nil, errors.Errorf("expected the number of rows but found %s", describe(tok))
+		}
+		if q.Limit, err = strconv.Atoi(tok.value); err != nil {
+			return nil, err
+		}
+	}
+
+	p.accept(";")
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, errors.Errorf("unexpected %s", describe(tok))
+	}
+	return q, nil
+}
+
+func (p *parser) ident() (string, error) {
+	tok := p.next()
+	if tok.kind != tokenIdent {
+		return "", errors.Errorf("expected a name but found %s", describe(tok))
+	}
+	return tok.value, nil
+}
+
+func (p *parser) table() (Table, error) {
+	name, err := p.ident()
+	if err != nil {
+		return Table{}, err
+	}
+
+	t := Table{Name: name, Alias: name}
+	if p.accept("AS") || p.peek().kind == tokenIdent {
+		if t.Alias, err = p.ident(); err != nil {
+			return Table{}, err
+		}
+	}
+	return t, nil
+}
+
+func (p *parser) selectItem() (SelectItem, error) {
+	e, err := p.expr()
+	if err != nil {
+		return SelectItem{}, err
+	}
+
+	item := SelectItem{Expr: e}
+	if p.accept("AS") || p.peek().kind == tokenIdent {
+		if item.Alias, err = p.ident(); err != nil {
+			return SelectItem{}, err
+		}
+	}
+	return item, nil
+}
+
+func (p *parser) orderItem() (OrderItem, error) {
+	var item OrderItem
+	if tok := p.peek(); tok.kind == tokenNumber {
+		p.next()
+		item.Position, _ = strconv.Atoi(tok.value)
+	} else {
+		e, err := p.expr()
+		if err != nil {
+			return item, err
+		}
+		item.Expr = e
+	}
+
+	if p.accept("DESC") {
+		item.Desc = true
+	} else {
+		p.accept("ASC")
+	}
+	return item, nil
+}
+
+func (p *parser) conjunction() ([]Predicate, error) {
+	var predicates []Predicate
+	for {
+		pred, err := p.predicate()
+		if err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, pred)
+
+		if p.peek().text == "OR" {
+			return nil, errors.New("OR is not supported, use IN instead")
+		}
+		if !p.accept("AND") {
+			return predicates, nil
+		}
+	}
+}
+
+func (p *parser) predicate() (Predicate, error) {
+	left, err := p.expr()
+	if err != nil {
+		return Predicate{}, err
+	}
+	pred := Predicate{Left: left}
+
+	not := p.accept("NOT")
+	switch {
+	case p.accept("IN"):
+		pred.Op = "IN"
+		if err := p.expect("("); err != nil {
+			return pred, err
+		}
+		for {
+			v, err := p.expr()
+			if err != nil {
+				return pred, err
+			}
+			pred.Values = append(pred.Values, v)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return pred, err
+		}
+	case p.accept("LIKE"):
+		pred.Op = "LIKE"
+		if pred.Right, err = p.expr(); err != nil {
+			return pred, err
+		}
+	case not:
+		return pred, errors.Errorf("expected IN or LIKE after NOT but found %s", describe(p.peek()))
+	default:
+		tok := p.next()
+		switch tok.text {
+		case "=", "!=", "<", "<=", ">", ">=":
+			pred.Op = tok.text
+		case "<>":
+			pred.Op = "!="
+		default:
+			return pred, errors.Errorf("expected a comparison but found %s", describe(tok))
+		}
+		if pred.Right, err = p.expr(); err != nil {
+			return pred, err
+		}
+	}
+
+	if not {
+		pred.Op = "NOT " + pred.Op
+	}
+	return pred, nil
+}
+
+func (p *parser) expr() (Expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &Literal{Value: n}, nil
+	case tokenString:
+		return &Literal{Value: tok.value}, nil
+	case tokenKeyword:
+		switch tok.text {
+		case "TRUE":
+			return &Literal{Value: true}, nil
+		case "FALSE":
+			return &Literal{Value: false}, nil
+		case "NULL":
+			return &Literal{}, nil
+		}
+	case tokenIdent:
+		if p.accept("(") {
+			return p.call(tok.value)
+		}
+		if p.accept(".") {
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			return &Column{Table: tok.value, Name: name}, nil
+		}
+		return &Column{Name: tok.value}, nil
+	}
+	return nil, errors.Errorf("expected an expression but found %s", describe(tok))
+}
+
+func (p *parser) call(name string) (Expr, error) {
+	c := &Call{Name: strings.ToUpper(name)}
+	if p.accept("*") {
+		c.Star = true
+	} else if p.peek().text != ")" {
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			c.Args = append(c.Args, e)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
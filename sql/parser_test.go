@@ -0,0 +1,142 @@
+package sql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected *Query
+	}{
+		{
+			"SELECT * FROM blobs",
+			&Query{From: Table{Name: "blobs", Alias: "blobs"}, Limit: -1},
+		},
+		{
+			"select refName, r.repositoryID AS repo from references r where refName = 'refs/heads/master' and isRemote = FALSE limit 5;",
+			&Query{
+				Select: []SelectItem{
+					{Expr: &Column{Name: "refName"}},
+					{Expr: &Column{Table: "r", Name: "repositoryID"}, Alias: "repo"},
+				},
+				From: Table{Name: "references", Alias: "r"},
+				Where: []Predicate{
+					{Left: &Column{Name: "refName"}, Op: "=", Right: &Literal{Value: "refs/heads/master"}},
+					{Left: &Column{Name: "isRemote"}, Op: "=", Right: &Literal{Value: false}},
+				},
+				Limit: 5,
+			},
+		},
+		{
+			"SELECT LANGUAGE(path, content) AS lang, COUNT(*) FROM blobs GROUP BY lang ORDER BY 2 DESC LIMIT 10",
+			&Query{
+				Select: []SelectItem{
+					{Expr: &Call{Name: "LANGUAGE", Args: []Expr{&Column{Name: "path"}, &Column{Name: "content"}}}, Alias: "lang"},
+					{Expr: &Call{Name: "COUNT", Star: true}},
+				},
+				From:    Table{Name: "blobs", Alias: "blobs"},
+				GroupBy: []Expr{&Column{Name: "lang"}},
+				OrderBy: []OrderItem{{Position: 2, Desc: true}},
+				Limit:   10,
+			},
+		},
+		{
+			`SELECT c.authorName, "b".path FROM commits AS c INNER JOIN blobs b ON b.commitHash = c.commitHash AND b.repositoryID = c.repositoryID ORDER BY c.authorName ASC, path DESC`,
+			&Query{
+				Select: []SelectItem{
+					{Expr: &Column{Table: "c", Name: "authorName"}},
+					{Expr: &Column{Table: "b", Name: "path"}},
+				},
+				From: Table{Name: "commits", Alias: "c"},
+				Joins: []Join{{
+					Table: Table{Name: "blobs", Alias: "b"},
+					On: []Predicate{
+						{Left: &Column{Table: "b", Name: "commitHash"}, Op: "=", Right: &Column{Table: "c", Name: "commitHash"}},
+						{Left: &Column{Table: "b", Name: "repositoryID"}, Op: "=", Right: &Column{Table: "c", Name: "repositoryID"}},
+					},
+				}},
+				OrderBy: []OrderItem{
+					{Expr: &Column{Table: "c", Name: "authorName"}},
+					{Expr: &Column{Name: "path"}, Desc: true},
+				},
+				Limit: -1,
+			},
+		},
+		{
+			"SELECT path FROM blobs WHERE path NOT LIKE '%.md' AND blobSize >= 10 AND 100 > blobSize AND path <> 'it''s' AND refName NOT IN ('a', 'b') -- comment",
+			&Query{
+				Select: []SelectItem{{Expr: &Column{Name: "path"}}},
+				From:   Table{Name: "blobs", Alias: "blobs"},
+				Where: []Predicate{
+					{Left: &Column{Name: "path"}, Op: "NOT LIKE", Right: &Literal{Value: "%.md"}},
+					{Left: &Column{Name: "blobSize"}, Op: ">=", Right: &Literal{Value: int64(10)}},
+					{Left: &Literal{Value: int64(100)}, Op: ">", Right: &Column{Name: "blobSize"}},
+					{Left: &Column{Name: "path"}, Op: "!=", Right: &Literal{Value: "it's"}},
+					{Left: &Column{Name: "refName"}, Op: "NOT IN", Values: []Expr{&Literal{Value: "a"}, &Literal{Value: "b"}}},
+				},
+				Limit: -1,
+			},
+		},
+		{
+			"SELECT MIN(blobSize) small, max(blobSize), SUM(blobSize), lower(path) FROM blobs",
+			&Query{
+				Select: []SelectItem{
+					{Expr: &Call{Name: "MIN", Args: []Expr{&Column{Name: "blobSize"}}}, Alias: "small"},
+					{Expr: &Call{Name: "MAX", Args: []Expr{&Column{Name: "blobSize"}}}},
+					{Expr: &Call{Name: "SUM", Args: []Expr{&Column{Name: "blobSize"}}}},
+					{Expr: &Call{Name: "LOWER", Args: []Expr{&Column{Name: "path"}}}},
+				},
+				From:  Table{Name: "blobs", Alias: "blobs"},
+				Limit: -1,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		q, err := Parse(c.query)
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %v", c.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(q, c.expected) {
+			t.Errorf("parsing %q\nexpected %#v\ngot      %#v", c.query, c.expected, q)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		query, err string
+	}{
+		{"", "syntax error at position 0: expected SELECT but found end of query"},
+		{"SELECT path", "expected FROM but found end of query"},
+		{"SELECT path FROM", "expected a name but found end of query"},
+		{"SELECT path, FROM blobs", `expected an expression but found "FROM"`},
+		{"SELECT path FROM blobs WHERE path = 'a' OR path = 'b'", "OR is not supported, use IN instead"},
+		{"SELECT path FROM blobs WHERE path NOT = 'a'", "expected IN or LIKE after NOT"},
+		{"SELECT path FROM blobs WHERE path", "expected a comparison but found end of query"},
+		{"SELECT path FROM blobs WHERE path IN 'a'", `expected ( but found "'a'"`},
+		{"SELECT path FROM blobs JOIN commits", "expected ON but found end of query"},
+		{"SELECT path FROM blobs GROUP path", `expected BY but found "path"`},
+		{"SELECT path FROM blobs LIMIT all", `expected the number of rows but found "all"`},
+		{"SELECT COUNT(* FROM blobs", `expected ) but found "FROM"`},
+		{"SELECT path FROM blobs blobs2 extra", `unexpected "extra"`},
+		{"SELECT path FROM blobs WHERE path = 'a", "unterminated quoted string"},
+		{"SELECT path FROM blobs WHERE path ! 'a'", "unexpected character '!'"},
+		{"SELECT path FROM blobs WHERE path = 'a' + 1", "unexpected character '+'"},
+	}
+
+	for _, c := range cases {
+		_, err := Parse(c.query)
+		if err == nil {
+			t.Errorf("expected an error parsing %q", c.query)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected the error parsing %q to contain %q, got %q", c.query, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,661 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chrislusf/gleam/flow"
+	engine "github.com/eiso/go-engine"
+	"github.com/eiso/go-engine/dataset"
+	"github.com/eiso/go-engine/readers"
+	"github.com/pkg/errors"
+)
+
+// Tables lists the tables queries can read from, which are the steps of the
+// engine sources: repositories, references, commits, trees and blobs. The
+// commits, trees and blobs tables read the commits the references point
+// to, like engine.Repositories(path).References().Commits().
+var Tables = []string{"repositories", "references", "commits", "trees", "blobs"}
+
+// groupKey is the key all the rows are aggregated by when there is no
+// GROUP BY clause.
+const groupKey = "$group"
+
+// Compile parses the query and builds the flow reading the repositories at
+// path that runs it. The columns of the resulting dataset are named after
+// the selected expressions or their aliases.
+//
+// Conditions of the WHERE clause are evaluated while reading the
+// repositories, and conditions on the refName of the references table
+// limit the references read.
+func Compile(f *flow.Flow, query, path string, partitionCount int) (*dataset.Dataset, error) {
+	q, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &planner{
+		flow:           f,
+		path:           path,
+		partitionCount: partitionCount,
+		columns:        make(map[string]string),
+	}
+	return p.plan(q)
+}
+
+type planner struct {
+	flow           *flow.Flow
+	path           string
+	partitionCount int
+	tables         []*table
+	// columns maps the expressions already computed, and the columns
+	// renamed by joins, to their column in the dataset.
+	columns map[string]string
+	steps   int
+}
+
+type table struct {
+	Table
+	schema     readers.Schema
+	conditions []readers.Condition
+	refs       []string
+}
+
+func (p *planner) name(step string) string {
+	p.steps++
+	return fmt.Sprintf("sql.%d.%s", p.steps, step)
+}
+
+func (p *planner) plan(q *Query) (*dataset.Dataset, error) {
+	for _, t := range append([]Table{q.From}, joinedTables(q.Joins)...) {
+		if err := p.addTable(t); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pred := range q.Where {
+		if err := p.pushDown(pred); err != nil {
+			return nil, err
+		}
+	}
+
+	d, err := p.read(p.tables[0])
+	if err != nil {
+		return nil, err
+	}
+	for i, j := range q.Joins {
+		if d, err = p.join(d, p.tables[i+1], j.On); err != nil {
+			return nil, err
+		}
+	}
+
+	items := q.Select
+	if len(items) == 0 {
+		if len(q.GroupBy) > 0 {
+			return nil, errors.New("SELECT * can't be used with GROUP BY")
+		}
+		for _, c := range d.Columns() {
+			item := SelectItem{Expr: p.columnExpr(c)}
+			if len(p.tables) == 1 {
+				item.Alias = item.Expr.(*Column).Name
+			}
+			items = append(items, item)
+		}
+	}
+
+	orderBy, err := p.orderExprs(q.OrderBy, items)
+	if err != nil {
+		return nil, err
+	}
+	groupBy := make([]Expr, len(q.GroupBy))
+	for i, e := range q.GroupBy {
+		groupBy[i] = resolveAlias(e, items)
+	}
+
+	exprs := append(append(selectExprs(items), groupBy...), orderBy...)
+	if d, err = p.computeCalls(d, exprs); err != nil {
+		return nil, err
+	}
+
+	if len(groupBy) > 0 || hasAggregates(exprs) {
+		if d, err = p.aggregate(d, groupBy, exprs); err != nil {
+			return nil, err
+		}
+	}
+
+	if d, err = p.orderAndLimit(d, q, orderBy); err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, len(items))
+	names := make([]string, len(items))
+	for i, item := range items {
+		if columns[i], err = p.column(item.Expr); err != nil {
+			return nil, err
+		}
+		names[i] = item.Alias
+		if names[i] == "" {
+			names[i] = item.Expr.String()
+		}
+	}
+
+	return d.Select(p.name("select"), columns...).Rename(names...), nil
+}
+
+func joinedTables(joins []Join) []Table {
+	tables := make([]Table, len(joins))
+	for i, j := range joins {
+		tables[i] = j.Table
+	}
+	return tables
+}
+
+func (p *planner) addTable(t Table) error {
+	for _, other := range p.tables {
+		if other.Alias == t.Alias {
+			return errors.Errorf("table %s is used twice, give it another alias", t.Alias)
+		}
+	}
+
+	schema, err := readers.SchemaOf(strings.ToLower(t.Name))
+	if err != nil {
+		return errors.Errorf("unknown table %s, tables are %s", t.Name, strings.Join(Tables, ", "))
+	}
+	t.Name = strings.ToLower(t.Name)
+	p.tables = append(p.tables, &table{Table: t, schema: schema})
+	return nil
+}
+
+// source builds the engine source reading the table.
+func (p *planner) source(t *table) dataset.Source {
+	repos := engine.Repositories(p.path, p.partitionCount)
+	switch t.Name {
+	case "repositories":
+		return repos.Where(t.conditions...)
+	case "references":
+		return repos.References().Filter(t.refs...).Where(t.conditions...)
+	case "commits":
+		return repos.References().Commits().Where(t.conditions...)
+	case "trees":
+		return repos.References().Commits().Trees().Where(t.conditions...)
+	}
+	return repos.References().Commits().Trees().Blobs().Where(t.conditions...)
+}
+
+// read reads the table, naming its columns after the table alias.
+func (p *planner) read(t *table) (*dataset.Dataset, error) {
+	d, err := dataset.Read(p.flow, p.source(t))
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, len(d.Columns()))
+	for i, c := range d.Columns() {
+		columns[i] = t.Alias + "." + c
+	}
+	return d.Rename(columns...), nil
+}
+
+// resolve finds the table and the column a reference is about.
+func (p *planner) resolve(c *Column) (*table, string, error) {
+	var found *table
+	for _, t := range p.tables {
+		if c.Table != "" && c.Table != t.Alias {
+			continue
+		}
+		if t.schema.Index(c.Name) < 0 {
+			continue
+		}
+		if found != nil {
+			return nil, "", errors.Errorf("column %s is ambiguous", c)
+		}
+		found = t
+	}
+
+	if found == nil {
+		return nil, "", errors.Errorf("unknown column %s", c)
+	}
+	return found, found.Alias + "." + c.Name, nil
+}
+
+// columnExpr returns the expression referring to a column of the dataset.
+func (p *planner) columnExpr(column string) Expr {
+	parts := strings.SplitN(column, ".", 2)
+	return &Column{Table: parts[0], Name: parts[1]}
+}
+
+// column returns the column of the dataset holding the value of e, once it
+// has been computed.
+func (p *planner) column(e Expr) (string, error) {
+	if c, ok := e.(*Column); ok {
+		_, name, err := p.resolve(c)
+		if err != nil {
+			return "", err
+		}
+		if renamed, ok := p.columns[name]; ok {
+			return renamed, nil
+		}
+		return name, nil
+	}
+
+	key, err := p.key(e)
+	if err != nil {
+		return "", err
+	}
+	if name, ok := p.columns[key]; ok {
+		return name, nil
+	}
+	return "", errors.Errorf("%s can't be used here", e)
+}
+
+// key identifies an expression whatever the way its columns are written.
+func (p *planner) key(e Expr) (string, error) {
+	switch e := e.(type) {
+	case *Column:
+		_, name, err := p.resolve(e)
+		return name, err
+	case *Call:
+		if e.Star {
+			return e.Name + "(*)", nil
+		}
+		args := make([]string, len(e.Args))
+		for i, a := range e.Args {
+			var err error
+			if args[i], err = p.key(a); err != nil {
+				return "", err
+			}
+		}
+		return e.Name + "(" + strings.Join(args, ", ") + ")", nil
+	}
+	return "", errors.Errorf("%s can't be used here", e)
+}
+
+// pushDown adds a condition of the WHERE clause to the table it is about.
+func (p *planner) pushDown(pred Predicate) error {
+	col, ok := pred.Left.(*Column)
+	if !ok {
+		if right, isCol := pred.Right.(*Column); isCol && pred.Values == nil {
+			col, ok = right, true
+			pred.Left, pred.Right = right, pred.Left
+			pred.Op = flip(pred.Op)
+		}
+	}
+	if !ok {
+		return errors.Errorf("conditions must compare a column with values: %s", predicateString(pred))
+	}
+
+	t, name, err := p.resolve(col)
+	if err != nil {
+		return err
+	}
+	column := t.schema[t.schema.Index(col.Name)]
+
+	values := pred.Values
+	if values == nil {
+		values = []Expr{pred.Right}
+	}
+	cond := readers.Condition{Column: col.Name, Op: pred.Op}
+	for _, v := range values {
+		lit, ok := v.(*Literal)
+		if !ok {
+			return errors.Errorf("%s can only be compared with values in WHERE, use JOIN ... ON instead", name)
+		}
+		value, err := literalValue(lit, column, pred.Op)
+		if err != nil {
+			return errors.Wrapf(err, "invalid condition on %s", name)
+		}
+		cond.Values = append(cond.Values, value)
+	}
+	t.conditions = append(t.conditions, cond)
+
+	// only the references asked for are read
+	if t.Name == "references" && col.Name == "refName" && (pred.Op == readers.OpEq || pred.Op == readers.OpIn) {
+		for _, v := range cond.Values {
+			t.refs = append(t.refs, v.(string))
+		}
+	}
+	return nil
+}
+
+func flip(op string) string {
+	switch op {
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	}
+	return op
+}
+
+func predicateString(pred Predicate) string {
+	if pred.Values == nil {
+		return fmt.Sprintf("%s %s %s", pred.Left, pred.Op, pred.Right)
+	}
+	values := make([]string, len(pred.Values))
+	for i, v := range pred.Values {
+		values[i] = v.String()
+	}
+	return fmt.Sprintf("%s %s (%s)", pred.Left, pred.Op, strings.Join(values, ", "))
+}
+
+// literalValue checks that the literal has the type of the column.
+func literalValue(lit *Literal, column readers.Column, op string) (interface{}, error) {
+	if lit.Value == nil {
+		return nil, errors.New("NULL can't be compared")
+	}
+	if op == readers.OpLike || op == readers.OpNotLike {
+		if _, ok := lit.Value.(string); !ok {
+			return nil, errors.Errorf("LIKE needs a string pattern but found %s", lit)
+		}
+		return lit.Value, nil
+	}
+
+	ok := false
+	switch column.Type {
+	case readers.String, readers.Bytes:
+		_, ok = lit.Value.(string)
+	case readers.Int64:
+		_, ok = lit.Value.(int64)
+	case readers.Bool:
+		_, ok = lit.Value.(bool)
+	}
+	if !ok {
+		return nil, errors.Errorf("%s is not a %s", lit, column.Type)
+	}
+	return lit.Value, nil
+}
+
+// join joins the table to the dataset by the equalities of the ON clause,
+// each one between a column of the table and a column of the dataset.
+func (p *planner) join(d *dataset.Dataset, t *table, on []Predicate) (*dataset.Dataset, error) {
+	right, err := p.read(t)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	renamed := make(map[string]string)
+	for _, pred := range on {
+		l, lok := pred.Left.(*Column)
+		r, rok := pred.Right.(*Column)
+		if pred.Op != readers.OpEq || !lok || !rok {
+			return nil, errors.Errorf("joins need equalities between columns: %s", predicateString(pred))
+		}
+
+		lt, lname, err := p.resolve(l)
+		if err != nil {
+			return nil, err
+		}
+		_, rname, err := p.resolve(r)
+		if err != nil {
+			return nil, err
+		}
+		if lt == t {
+			lname, rname = rname, lname
+		}
+		if !strings.HasPrefix(rname, t.Alias+".") || strings.HasPrefix(lname, t.Alias+".") {
+			return nil, errors.Errorf("join conditions must compare %s with the previous tables: %s", t.Alias, predicateString(pred))
+		}
+
+		if c, ok := p.columns[lname]; ok {
+			lname = c
+		}
+		keys = append(keys, lname)
+		renamed[rname] = lname
+	}
+
+	columns := make([]string, len(right.Columns()))
+	for i, c := range right.Columns() {
+		columns[i] = c
+		if k, ok := renamed[c]; ok {
+			columns[i] = k
+		}
+	}
+	right = right.Rename(columns...)
+
+	for r, l := range renamed {
+		p.columns[r] = l
+	}
+	return d.Join(p.name("join"), right, keys...), nil
+}
+
+func selectExprs(items []SelectItem) []Expr {
+	exprs := make([]Expr, len(items))
+	for i, item := range items {
+		exprs[i] = item.Expr
+	}
+	return exprs
+}
+
+// resolveAlias replaces references to aliases of selected expressions with
+// the expressions.
+func resolveAlias(e Expr, items []SelectItem) Expr {
+	c, ok := e.(*Column)
+	if !ok || c.Table != "" {
+		return e
+	}
+	for _, item := range items {
+		if item.Alias != "" && item.Alias == c.Name {
+			return item.Expr
+		}
+	}
+	return e
+}
+
+func (p *planner) orderExprs(order []OrderItem, items []SelectItem) ([]Expr, error) {
+	exprs := make([]Expr, len(order))
+	for i, o := range order {
+		if o.Expr == nil {
+			if o.Position < 1 || o.Position > len(items) {
+				return nil, errors.Errorf("ORDER BY position %d is not in the select list", o.Position)
+			}
+			exprs[i] = items[o.Position-1].Expr
+			continue
+		}
+		exprs[i] = resolveAlias(o.Expr, items)
+	}
+	return exprs, nil
+}
+
+func isAggregate(e Expr) bool {
+	c, ok := e.(*Call)
+	if !ok {
+		return false
+	}
+	_, ok = aggregates[c.Name]
+	return ok
+}
+
+func hasAggregates(exprs []Expr) bool {
+	for _, e := range exprs {
+		if isAggregate(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// computeCalls adds a column for every call to a scalar function.
+func (p *planner) computeCalls(d *dataset.Dataset, exprs []Expr) (*dataset.Dataset, error) {
+	for _, e := range exprs {
+		c, ok := e.(*Call)
+		if !ok {
+			continue
+		}
+		if isAggregate(c) {
+			for _, a := range c.Args {
+				if _, ok := a.(*Column); !ok {
+					return nil, errors.Errorf("aggregations can only be applied to columns: %s", c)
+				}
+			}
+			continue
+		}
+
+		fn, ok := functions[c.Name]
+		if !ok {
+			return nil, errors.Errorf("unknown function %s", c.Name)
+		}
+		if len(c.Args) != fn.args || c.Star {
+			return nil, errors.Errorf("%s takes %d arguments", c.Name, fn.args)
+		}
+
+		key, err := p.key(c)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.columns[key]; ok {
+			continue
+		}
+
+		columns := d.Columns()
+		in := append([]string(nil), columns...)
+		for _, a := range c.Args {
+			col, ok := a.(*Column)
+			if !ok {
+				return nil, errors.Errorf("functions can only be applied to columns: %s", c)
+			}
+			name, err := p.column(col)
+			if err != nil {
+				return nil, err
+			}
+			in = append(in, name)
+		}
+
+		step := p.name(strings.ToLower(c.Name))
+		args := d.Select(step+".args", in...)
+		d = args.Derive(args.Dataset.Map(step, fn.mapper), append(columns, key)...)
+		p.columns[key] = key
+	}
+	return d, nil
+}
+
+// aggregate aggregates the rows by the keys, computing every aggregation in
+// its own reduce step and joining them all by the keys.
+func (p *planner) aggregate(d *dataset.Dataset, groupBy []Expr, exprs []Expr) (*dataset.Dataset, error) {
+	var keys []string
+	for _, e := range groupBy {
+		if isAggregate(e) {
+			return nil, errors.Errorf("%s can't be grouped by", e)
+		}
+		name, err := p.column(e)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, name)
+	}
+
+	for _, e := range exprs {
+		if isAggregate(e) {
+			continue
+		}
+		name, err := p.column(e)
+		if err != nil {
+			return nil, err
+		}
+		if !contains(keys, name) {
+			return nil, errors.Errorf("%s must be aggregated or appear in GROUP BY", e)
+		}
+	}
+
+	var result *dataset.Dataset
+	for _, e := range exprs {
+		if !isAggregate(e) {
+			continue
+		}
+		c := e.(*Call)
+		key, err := p.key(c)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.columns[key]; ok {
+			continue
+		}
+
+		agg, err := p.reduce(d, keys, c, key)
+		if err != nil {
+			return nil, err
+		}
+		p.columns[key] = key
+
+		if result == nil {
+			result = agg
+		} else {
+			result = result.Join(p.name("aggregations"), agg, result.Columns()[:len(agg.Columns())-1]...)
+		}
+	}
+
+	if result == nil {
+		// only grouped columns are selected
+		return p.reduce(d, keys, &Call{Name: "COUNT", Star: true}, "$count")
+	}
+	return result, nil
+}
+
+// reduce computes the aggregation c grouped by keys into a dataset with the
+// keys and the aggregated column.
+func (p *planner) reduce(d *dataset.Dataset, keys []string, c *Call, column string) (*dataset.Dataset, error) {
+	step := p.name(strings.ToLower(c.Name))
+
+	mapper := aggregates[c.Name]
+	var value string
+	switch {
+	case c.Star && c.Name == "COUNT":
+		mapper = countAll
+		value = d.Columns()[0]
+	case len(c.Args) == 1 && !c.Star:
+		var err error
+		if value, err = p.column(c.Args[0]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("%s takes a single column", c.Name)
+	}
+
+	in := d.Select(step+".select", append(append([]string(nil), keys...), value)...)
+	groupKeys := keys
+	if len(keys) == 0 {
+		groupKeys = []string{groupKey}
+		in = dataset.New(in.Dataset.Map(step+".key", prependKey), groupKey, value)
+	}
+
+	grouped := in.Rename(append(append([]string(nil), groupKeys...), step+".value")...).
+		GroupBy(step+".group", groupKeys...)
+	return grouped.Derive(grouped.Dataset.Map(step, mapper), append(append([]string(nil), groupKeys...), column)...), nil
+}
+
+func (p *planner) orderAndLimit(d *dataset.Dataset, q *Query, orderBy []Expr) (*dataset.Dataset, error) {
+	if len(orderBy) == 0 {
+		if q.Limit < 0 {
+			return d, nil
+		}
+		step := p.name("limit")
+		return d.Derive(d.Dataset.MergeTo(step, 1).LocalLimit(step, q.Limit, 0), d.Columns()...), nil
+	}
+
+	orders := make([]dataset.Order, len(orderBy))
+	for i, e := range orderBy {
+		name, err := p.column(e)
+		if err != nil {
+			return nil, err
+		}
+		orders[i] = dataset.Order{Column: name, Ascending: !q.OrderBy[i].Desc}
+	}
+
+	if q.Limit < 0 {
+		return d.Sort(p.name("sort"), orders...), nil
+	}
+	return d.Top(p.name("top"), q.Limit, orders...), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+package sql
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/gleam/flow"
+	"github.com/chrislusf/gleam/gio"
+	"github.com/chrislusf/gleam/util"
+	"github.com/eiso/go-engine/readers"
+	"github.com/eiso/go-engine/sink"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// TestMain lets the test binary run the mappers of the flows, which Gleam
+// runs as separate processes of the binary found in the PATH by its name.
+func TestMain(m *testing.M) {
+	gio.Init()
+
+	if ex, err := os.Executable(); err == nil {
+		os.Setenv("PATH", filepath.Dir(ex)+string(os.PathListSeparator)+os.Getenv("PATH"))
+	}
+	os.Exit(m.Run())
+}
+
+// newRepositories creates the repositories the queries are tested with:
+//
+//	a: main.go, lib/util.go and README.md in master, plus dev.go in dev
+//	b: index.js in master
+func newRepositories(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newRepository(t, filepath.Join(dir, "a"), "initial commit", map[string]string{
+		"main.go":     "package main\n\nfunc main() {}\n",
+		"lib/util.go": "package lib\n",
+		"README.md":   "# a\n",
+	})
+	w, err := a.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Branch: "refs/heads/dev", Create: true}); err != nil {
+		t.Fatal(err)
+	}
+	commit(t, a, filepath.Join(dir, "a"), "add dev", map[string]string{"dev.go": "package main\n"})
+	if err := w.Checkout(&git.CheckoutOptions{Branch: plumbing.Master}); err != nil {
+		t.Fatal(err)
+	}
+
+	newRepository(t, filepath.Join(dir, "b"), "add index", map[string]string{
+		"index.js": "console.log(1)\n",
+	})
+	return dir
+}
+
+func newRepository(t *testing.T, path, message string, files map[string]string) *git.Repository {
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit(t, repo, path, message, files)
+	return repo
+}
+
+func commit(t *testing.T, repo *git.Repository, path, message string, files map[string]string) {
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, content := range files {
+		if err := os.MkdirAll(filepath.Join(path, filepath.Dir(file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(path, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = w.Commit(message, &git.CommitOptions{Author: &object.Signature{
+		Name:  "author",
+		Email: "author@example.com",
+		When:  time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// run compiles and runs the query, returning its columns and its rows with
+// their values separated by spaces, and repository paths relative to dir.
+func run(t *testing.T, dir, query string) ([]string, []string) {
+	f := flow.New("test")
+	d, err := Compile(f, query, dir, 2)
+	if err != nil {
+		t.Fatalf("compiling %q: %v", query, err)
+	}
+
+	var rows []string
+	d.OutputRow(func(row *util.Row) error {
+		var values []string
+		for _, v := range append(row.K, row.V...) {
+			s := fmt.Sprint(v)
+			if b, ok := v.([]byte); ok {
+				s = string(b)
+			}
+			if rel, err := filepath.Rel(dir, s); err == nil && !strings.HasPrefix(rel, "..") {
+				s = rel
+			}
+			values = append(values, s)
+		}
+		rows = append(rows, strings.Join(values, " "))
+		return nil
+	})
+	f.Run()
+	return d.Columns(), rows
+}
+
+func TestCompile(t *testing.T) {
+	dir := newRepositories(t)
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		query   string
+		columns []string
+		rows    []string
+	}{
+		{
+			// the example of the README
+			"SELECT LANGUAGE(path, content) AS lang, COUNT(*) FROM blobs GROUP BY lang ORDER BY 2 DESC, 1 LIMIT 10",
+			[]string{"lang", "COUNT(*)"},
+			[]string{"Go 5", "Markdown 2", "JavaScript 1"},
+		},
+		{
+			"SELECT repositoryID, COUNT(*) files, SUM(blobSize), MIN(path), MAX(blobSize) FROM blobs GROUP BY repositoryID ORDER BY repositoryID",
+			[]string{"repositoryID", "files", "SUM(blobSize)", "MIN(path)", "MAX(blobSize)"},
+			[]string{"a 7 103 README.md 29", "b 1 15 index.js 15"},
+		},
+		{
+			"SELECT r.refName, c.message FROM references r JOIN commits AS c ON c.commitHash = r.refHash AND r.repositoryID = c.repositoryID WHERE r.refName LIKE 'refs/heads/%' ORDER BY 1, 2",
+			[]string{"r.refName", "c.message"},
+			[]string{"refs/heads/dev add dev", "refs/heads/master add index", "refs/heads/master initial commit"},
+		},
+		{
+			"SELECT repositoryID, refName FROM references WHERE refName = 'refs/heads/dev'",
+			[]string{"repositoryID", "refName"},
+			[]string{"a refs/heads/dev"},
+		},
+		{
+			"SELECT UPPER(path), blobSize FROM blobs WHERE repositoryID LIKE '%/b' ORDER BY 2 DESC",
+			[]string{"UPPER(path)", "blobSize"},
+			[]string{"INDEX.JS 15"},
+		},
+	}
+
+	for _, c := range cases {
+		columns, rows := run(t, dir, c.query)
+		if !reflect.DeepEqual(columns, c.columns) {
+			t.Errorf("expected columns %v of %q, got %v", c.columns, c.query, columns)
+		}
+		if !reflect.DeepEqual(rows, c.rows) {
+			t.Errorf("expected rows %q of %q, got %q", c.rows, c.query, rows)
+		}
+	}
+}
+
+func TestCompileSchema(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected readers.Schema
+	}{
+		{
+			"SELECT b.content AS c, path, LOWER(path) FROM blobs b",
+			readers.Schema{
+				{Name: "c", Type: readers.Bytes},
+				{Name: "path", Type: readers.String},
+				{Name: "LOWER(path)", Nullable: true},
+			},
+		},
+		{
+			"SELECT r.isRemote, c.parentsCount FROM references r JOIN commits c ON c.commitHash = r.refHash LIMIT 1",
+			readers.Schema{
+				{Name: "r.isRemote", Type: readers.Bool},
+				{Name: "c.parentsCount", Type: readers.Int64},
+			},
+		},
+		{
+			"SELECT repositoryID, COUNT(*) FROM blobs GROUP BY repositoryID ORDER BY 2",
+			readers.Schema{
+				{Name: "repositoryID", Type: readers.String},
+				{Name: "COUNT(*)", Nullable: true},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		d, err := Compile(flow.New("test"), c.query, "repos", 1)
+		if err != nil {
+			t.Errorf("compiling %q: %v", c.query, err)
+			continue
+		}
+		if got := d.Schema(); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("expected the schema %v of %q, got %v", c.expected, c.query, got)
+		}
+	}
+}
+
+func TestCompileBytes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newRepository(t, filepath.Join(dir, "a"), "add image", map[string]string{
+		"image.png": "\x89PNG\xff\x00",
+	})
+
+	f := flow.New("test")
+	d, err := Compile(f, "SELECT path, content FROM blobs", dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	sink.JSONL(d.Dataset, &buf, d.Schema())
+	f.Run()
+
+	expected := `{"path":"image.png","content":"iVBOR/8A"}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestCompileRefNames(t *testing.T) {
+	q, err := Parse("SELECT refName FROM references WHERE refName IN ('refs/heads/dev', 'refs/heads/other') AND isRemote = false")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &planner{flow: flow.New("test"), path: "repos", partitionCount: 1, columns: make(map[string]string)}
+	if _, err := p.plan(q); err != nil {
+		t.Fatal(err)
+	}
+
+	refs := p.tables[0].refs
+	if expected := []string{"refs/heads/dev", "refs/heads/other"}; !reflect.DeepEqual(refs, expected) {
+		t.Errorf("expected the references read to be %v, got %v", expected, refs)
+	}
+	if n := len(p.tables[0].conditions); n != 2 {
+		t.Errorf("expected 2 conditions on the references, got %d", n)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		query, err string
+	}{
+		{"SELECT path FROM files", "unknown table files"},
+		{"SELECT name FROM blobs", "unknown column name"},
+		{"SELECT repositoryID FROM references r JOIN commits c ON c.commitHash = r.refHash", "column repositoryID is ambiguous"},
+		{"SELECT path, COUNT(*) FROM blobs", "path must be aggregated or appear in GROUP BY"},
+		{"SELECT path FROM blobs ORDER BY 2", "ORDER BY position 2 is not in the select list"},
+		{"SELECT FOO(path) FROM blobs", "unknown function FOO"},
+		{"SELECT path FROM blobs b JOIN blobs b ON b.path = b.path", "table b is used twice"},
+		{"SELECT * FROM blobs GROUP BY path", "SELECT * can't be used with GROUP BY"},
+		{"SELECT path FROM blobs WHERE blobSize = 'big'", "invalid condition on blobs.blobSize"},
+	}
+
+	for _, c := range cases {
+		_, err := Compile(flow.New("test"), c.query, "repos", 1)
+		if err == nil {
+			t.Errorf("expected an error compiling %q", c.query)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected the error compiling %q to contain %q, got %q", c.query, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package udf
+
+import (
+	"path"
+	"strings"
+
+	"github.com/chrislusf/gleam/gio"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+// ClassifyFile appends to the rows whether the file is vendored, generated,
+// a test, documentation, configuration or a dot file, in that order, so
+// queries can leave out the files not written as part of the project.
+func ClassifyFile(filenameIdx, contentIdx int) gio.Mapper {
+	return func(x []interface{}) error {
+		filename := gio.ToString(x[filenameIdx])
+		content := gio.ToBytes(x[contentIdx])
+		return gio.Emit(append(x,
+			enry.IsVendor(filename),
+			IsGenerated(filename, content),
+			IsTest(filename),
+			enry.IsDocumentation(filename),
+			enry.IsConfiguration(filename),
+			enry.IsDotFile(filename),
+		)...)
+	}
+}
+
+// testDirs are the names of the directories holding tests.
+var testDirs = map[string]bool{
+	"test":      true,
+	"tests":     true,
+	"testdata":  true,
+	"__tests__": true,
+	"spec":      true,
+	"specs":     true,
+}
+
+// testSuffixes are the endings of the names of test files, without the
+// extension, like foo_test.go or FooTest.java.
+var testSuffixes = []string{"_test", "_spec", ".test", ".spec", "Test", "Tests", "Spec"}
+
+// IsTest reports whether the file at the path is a test, because of its
+// name or because it is in a test directory.
+func IsTest(filename string) bool {
+	dir, base := path.Split(filename)
+	for _, d := range strings.Split(dir, "/") {
+		if testDirs[d] {
+			return true
+		}
+	}
+
+	name := strings.TrimSuffix(base, path.Ext(base))
+	if strings.HasPrefix(name, "test_") {
+		return true
+	}
+	for _, suffix := range testSuffixes {
+		if strings.HasSuffix(name, suffix) && name != suffix {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package udf
+
+import (
+	"bytes"
+
+	"github.com/chrislusf/gleam/gio"
+)
+
+// commentSyntax is how comments are written in a language.
+type commentSyntax struct {
+	// line are the markers of the comments up to the end of the line.
+	line []string
+	// block are the start and end markers of the comments spanning
+	// several lines.
+	block [][2]string
+}
+
+var (
+	cComments      = commentSyntax{line: []string{"//"}, block: [][2]string{{"/*", "*/"}}}
+	hashComments   = commentSyntax{line: []string{"#"}}
+	dashComments   = commentSyntax{line: []string{"--"}}
+	semiComments   = commentSyntax{line: []string{";"}}
+	markupComments = commentSyntax{block: [][2]string{{"<!--", "-->"}}}
+)
+
+// commentSyntaxes are the comments of the languages, by their enry names.
+// Lines of other languages are either code or blank.
+var commentSyntaxes = map[string]commentSyntax{
+	"C":               cComments,
+	"C#":              cComments,
+	"C++":             cComments,
+	"CSS":             {block: [][2]string{{"/*", "*/"}}},
+	"Dart":            cComments,
+	"Go":              cComments,
+	"Groovy":          cComments,
+	"Java":            cComments,
+	"JavaScript":      cComments,
+	"JSX":             cComments,
+	"Kotlin":          cComments,
+	"Less":            cComments,
+	"Objective-C":     cComments,
+	"Protocol Buffer": cComments,
+	"Rust":            cComments,
+	"Scala":           cComments,
+	"SCSS":            cComments,
+	"Swift":           cComments,
+	"TypeScript":      cComments,
+	"PHP":             {line: []string{"//", "#"}, block: [][2]string{{"/*", "*/"}}},
+
+	"CMake":      hashComments,
+	"Dockerfile": hashComments,
+	"Elixir":     hashComments,
+	"Makefile":   hashComments,
+	"Perl":       hashComments,
+	"PowerShell": {line: []string{"#"}, block: [][2]string{{"<#", "#>"}}},
+	"Python":     {line: []string{"#"}, block: [][2]string{{`"""`, `"""`}, {"'''", "'''"}}},
+	"R":          hashComments,
+	"Ruby":       {line: []string{"#"}, block: [][2]string{{"=begin", "=end"}}},
+	"Shell":      hashComments,
+	"TOML":       hashComments,
+	"YAML":       hashComments,
+
+	"Ada":     dashComments,
+	"Haskell": {line: []string{"--"}, block: [][2]string{{"{-", "-}"}}},
+	"Lua":     {line: []string{"--"}, block: [][2]string{{"--[[", "]]"}}},
+	"SQL":     {line: []string{"--"}, block: [][2]string{{"/*", "*/"}}},
+
+	"Clojure":     semiComments,
+	"Common Lisp": {line: []string{";"}, block: [][2]string{{"#|", "|#"}}},
+	"Emacs Lisp":  semiComments,
+	"Scheme":      semiComments,
+
+	"Erlang": {line: []string{"%"}},
+	"Matlab": {line: []string{"%"}, block: [][2]string{{"%{", "%}"}}},
+	"TeX":    {line: []string{"%"}},
+
+	"F#":         {line: []string{"//"}, block: [][2]string{{"(*", "*)"}}},
+	"OCaml":      {block: [][2]string{{"(*", "*)"}}},
+	"Pascal":     {line: []string{"//"}, block: [][2]string{{"{", "}"}, {"(*", "*)"}}},
+	"Fortran":    {line: []string{"!"}},
+	"Vim script": {line: []string{`"`}},
+
+	"HTML": markupComments,
+	"Vue":  markupComments,
+	"XML":  markupComments,
+}
+
+// CountLines appends to the rows the number of lines of the content, and
+// how many of them are code, comments and blank, in that order, by the
+// comment syntax of the language, usually the one classified by
+// ClassifyLanguage. Lines with both code and comments count as code, and
+// comment markers inside strings are not told apart.
+func CountLines(langIdx, contentIdx int) gio.Mapper {
+	return func(x []interface{}) error {
+		syntax := commentSyntaxes[gio.ToString(x[langIdx])]
+		total, code, comment, blank := countLines(syntax, gio.ToBytes(x[contentIdx]))
+		return gio.Emit(append(x, total, code, comment, blank)...)
+	}
+}
+
+func countLines(syntax commentSyntax, content []byte) (total, code, comment, blank int64) {
+	// the end marker of the block comment the line starts in, if any
+	var end []byte
+	for len(content) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			line, content = content[:i], content[i+1:]
+		} else {
+			line, content = content, nil
+		}
+		total++
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			blank++
+			continue
+		}
+
+		var hasCode, hasComment bool
+		hasCode, hasComment, end = scanLine(syntax, line, end)
+		switch {
+		case hasCode:
+			code++
+		case hasComment:
+			comment++
+		}
+	}
+	return
+}
+
+// scanLine looks for code and comments in the line, starting in a block
+// comment if end is its end marker. It returns the end marker of the block
+// comment the line ends in, if any.
+func scanLine(syntax commentSyntax, line, end []byte) (hasCode, hasComment bool, _ []byte) {
+	for len(line) > 0 {
+		if end != nil {
+			hasComment = true
+			i := bytes.Index(line, end)
+			if i < 0 {
+				return hasCode, hasComment, end
+			}
+			line, end = line[i+len(end):], nil
+			continue
+		}
+
+		line = bytes.TrimLeft(line, " \t\r")
+		if len(line) == 0 {
+			break
+		}
+
+		if start, blockEnd, ok := blockStart(syntax, line); ok {
+			line, end = line[len(start):], []byte(blockEnd)
+			hasComment = true
+			continue
+		}
+		if lineComment(syntax, line) {
+			return hasCode, true, nil
+		}
+
+		hasCode = true
+		line = line[1:]
+	}
+	return hasCode, hasComment, end
+}
+
+func blockStart(syntax commentSyntax, line []byte) (start, end string, ok bool) {
+	for _, b := range syntax.block {
+		if bytes.HasPrefix(line, []byte(b[0])) {
+			return b[0], b[1], true
+		}
+	}
+	return "", "", false
+}
+
+func lineComment(syntax commentSyntax, line []byte) bool {
+	for _, l := range syntax.line {
+		if bytes.HasPrefix(line, []byte(l)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package udf
+
+import (
+	"github.com/chrislusf/gleam/gio"
+	"github.com/eiso/go-engine/license"
+)
+
+// DetectLicense appends to the rows the SPDX identifier of the license of
+// the file and the confidence of the detection, from license.MinConfidence
+// to 1, or nils if no license was detected. License files, like LICENSE or
+// COPYING, are compared whole with the texts of the licenses, and source
+// files by their SPDX-License-Identifier tags or license notices.
+func DetectLicense(filenameIdx, contentIdx int) gio.Mapper {
+	return func(x []interface{}) error {
+		m, ok := license.DetectFile(gio.ToString(x[filenameIdx]), gio.ToBytes(x[contentIdx]))
+		if !ok {
+			return gio.Emit(append(x, nil, nil)...)
+		}
+		return gio.Emit(append(x, m.ID, m.Confidence)...)
+	}
+}
@@ -0,0 +1,27 @@
+package udf
+
+import (
+	"github.com/chrislusf/gleam/gio"
+	"github.com/eiso/go-engine/imports"
+)
+
+// ExtractImports emits a row per package imported by the source code, or
+// declared as a dependency if the file is a manifest, see
+// imports.IsManifest, with its ecosystem, name and version appended. The
+// version is only known for the dependencies of manifests, and nil for
+// imports. The language is the one classified by ClassifyLanguage.
+func ExtractImports(filenameIdx, langIdx, contentIdx int) gio.Mapper {
+	return func(x []interface{}) error {
+		deps := imports.Extract(gio.ToString(x[filenameIdx]), gio.ToString(x[langIdx]), gio.ToBytes(x[contentIdx]))
+		for _, d := range deps {
+			var version interface{}
+			if d.Version != "" {
+				version = d.Version
+			}
+			if err := gio.Emit(append(x[:len(x):len(x)], d.Ecosystem, d.Name, version)...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
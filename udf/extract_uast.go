@@ -0,0 +1,155 @@
+package udf
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/gleam/gio"
+	"github.com/eiso/go-engine/bblfsh"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultBabelfishEndpoint is where ExtractUAST finds Babelfish by default.
+const DefaultBabelfishEndpoint = "localhost:9432"
+
+// BabelfishLanguages are the languages, as classified by enry, that
+// ExtractUAST parses by default.
+var BabelfishLanguages = []string{
+	"C#", "C++", "Go", "Java", "JavaScript", "PHP", "Python", "Ruby", "Shell", "TypeScript",
+}
+
+// babelfishNames are the names of the Babelfish drivers of the languages
+// which are not just the lower cased name.
+var babelfishNames = map[string]string{
+	"C#":    "csharp",
+	"C++":   "cpp",
+	"Shell": "bash",
+}
+
+// UASTOption configures ExtractUAST.
+type UASTOption func(*uastConfig)
+
+type uastConfig struct {
+	endpoint    string
+	timeout     time.Duration
+	connections int
+	languages   map[string]bool
+}
+
+// WithEndpoint sets the address of the Babelfish server.
+func WithEndpoint(endpoint string) UASTOption {
+	return func(c *uastConfig) { c.endpoint = endpoint }
+}
+
+// WithTimeout sets how long to wait for every file to be parsed, those
+// taking longer are skipped. It is 30 seconds by default.
+func WithTimeout(timeout time.Duration) UASTOption {
+	return func(c *uastConfig) { c.timeout = timeout }
+}
+
+// WithConnections sets the number of connections to the server used by
+// every mapper process, 4 by default.
+func WithConnections(n int) UASTOption {
+	return func(c *uastConfig) { c.connections = n }
+}
+
+// WithLanguages sets the only languages to parse instead of
+// BabelfishLanguages.
+func WithLanguages(languages ...string) UASTOption {
+	return func(c *uastConfig) { c.languages = languageSet(languages) }
+}
+
+func languageSet(languages []string) map[string]bool {
+	set := make(map[string]bool, len(languages))
+	for _, l := range languages {
+		set[l] = true
+	}
+	return set
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[string]*bblfsh.Client)
+)
+
+// babelfishClient returns the client of the endpoint, shared by all the
+// mappers of the process.
+func babelfishClient(endpoint string, connections int) *bblfsh.Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	c, ok := clients[endpoint]
+	if !ok {
+		c = bblfsh.NewClient(endpoint, connections)
+		clients[endpoint] = c
+	}
+	return c
+}
+
+// ExtractUAST parses the content of blobs with Babelfish, appending the UAST
+// serialized as a protocol buffer to the rows, or nil when the language of
+// the blob is not parsed or the content could not be parsed. The language
+// is the one classified by ClassifyLanguage.
+func ExtractUAST(langIdx, contentIdx int, opts ...UASTOption) gio.Mapper {
+	config := &uastConfig{
+		endpoint:    DefaultBabelfishEndpoint,
+		timeout:     30 * time.Second,
+		connections: 4,
+		languages:   languageSet(BabelfishLanguages),
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	return func(x []interface{}) error {
+		lang := gio.ToString(x[langIdx])
+		if !config.languages[lang] {
+			return gio.Emit(append(x, nil)...)
+		}
+
+		name, ok := babelfishNames[lang]
+		if !ok {
+			name = strings.ToLower(lang)
+		}
+
+		client := babelfishClient(config.endpoint, config.connections)
+		uast, err := parse(client, config, lang, &bblfsh.ParseRequest{
+			Language: name,
+			Content:  gio.ToString(x[contentIdx]),
+		})
+		if err != nil {
+			return err
+		}
+		return gio.Emit(append(x, uast)...)
+	}
+}
+
+// parse returns the UAST of the content, or nil when it could not be parsed
+// in time, its UAST is too large or the server failed to parse it.
+func parse(client *bblfsh.Client, config *uastConfig, lang string, req *bblfsh.ParseRequest) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.timeout)
+	defer cancel()
+
+	resp, err := client.Parse(ctx, req)
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.DeadlineExceeded:
+		log.Printf("skipping %s content that took longer than %v to parse", lang, config.timeout)
+		return nil, nil
+	case codes.ResourceExhausted:
+		log.Printf("skipping %s content with a UAST larger than %d bytes", lang, bblfsh.MaxMessageSize)
+		return nil, nil
+	default:
+		return nil, errors.Wrapf(err, "could not parse with babelfish at %s", config.endpoint)
+	}
+
+	if resp.Status == bblfsh.Fatal {
+		return nil, nil
+	}
+	return resp.UAST, nil
+}
@@ -0,0 +1,69 @@
+package udf
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/eiso/go-engine/bblfsh"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeBabelfish fails with the error of the language of the requests, and
+// parses the others.
+type fakeBabelfish struct {
+	errors map[string]error
+}
+
+func (s *fakeBabelfish) Parse(ctx context.Context, req *bblfsh.ParseRequest) (*bblfsh.ParseResponse, error) {
+	if err := s.errors[req.Language]; err != nil {
+		return nil, err
+	}
+	if req.Language == "fatal" {
+		return &bblfsh.ParseResponse{Status: bblfsh.Fatal}, nil
+	}
+	return &bblfsh.ParseResponse{Status: bblfsh.Ok, UAST: []byte(req.Content)}, nil
+}
+
+func TestParse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	bblfsh.RegisterServer(s, &fakeBabelfish{errors: map[string]error{
+		"slow":   status.Error(codes.DeadlineExceeded, "timeout"),
+		"large":  status.Error(codes.ResourceExhausted, "message larger than max"),
+		"broken": status.Error(codes.Internal, "driver crashed"),
+	}})
+	go s.Serve(l)
+	defer s.Stop()
+
+	client := bblfsh.NewClient(l.Addr().String(), 1)
+	defer client.Close()
+	config := &uastConfig{endpoint: l.Addr().String(), timeout: 5 * time.Second}
+
+	cases := []struct {
+		lang string
+		uast []byte
+		err  bool
+	}{
+		{"go", []byte("package a"), false},
+		{"fatal", nil, false},
+		{"slow", nil, false},
+		{"large", nil, false},
+		{"broken", nil, true},
+	}
+	for _, c := range cases {
+		uast, err := parse(client, config, c.lang, &bblfsh.ParseRequest{Language: c.lang, Content: "package a"})
+		if (err != nil) != c.err {
+			t.Errorf("unexpected error parsing %s: %v", c.lang, err)
+		}
+		if string(uast) != string(c.uast) || (uast == nil) != (c.uast == nil) {
+			t.Errorf("expected UAST %q for %s, got %q", c.uast, c.lang, uast)
+		}
+	}
+}
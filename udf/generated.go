@@ -0,0 +1,132 @@
+package udf
+
+import (
+	"bytes"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// generatedNames are the names of files written by package managers.
+var generatedNames = map[string]bool{
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"composer.lock":       true,
+	"Gopkg.lock":          true,
+	"glide.lock":          true,
+	"go.sum":              true,
+	"Cargo.lock":          true,
+	"Pipfile.lock":        true,
+	"poetry.lock":         true,
+}
+
+// generatedSuffixes are the endings of the names of files always written by
+// tools, the source maps and the forms of the Visual Studio designer.
+var generatedSuffixes = []string{
+	".js.map", ".css.map",
+	".designer.cs", ".designer.vb",
+}
+
+// generatedDirs are the directories holding only generated files.
+var generatedDirs = []string{
+	"node_modules/", "__generated__/", "Carthage/Build/",
+}
+
+// generatedRule flags the files with one of the extensions, or any file
+// when there are none, having a line matching the marker among their first
+// lines.
+type generatedRule struct {
+	extensions []string
+	lines      int
+	marker     *regexp.Regexp
+}
+
+// generatedRules are the comments left by the code generators, as GitHub
+// Linguist looks for them. Go files follow https://golang.org/s/generatedcode.
+var generatedRules = []generatedRule{
+	{[]string{".go"}, 40, regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)},
+	{
+		[]string{".py", ".java", ".h", ".cc", ".cpp", ".m", ".rb", ".php"}, 1,
+		regexp.MustCompile(`Generated by the protocol buffer compiler\.  DO NOT EDIT!`),
+	},
+	{[]string{".js"}, 6, regexp.MustCompile(`GENERATED CODE -- DO NOT EDIT!`)},
+	{nil, 6, regexp.MustCompile(`Autogenerated by Thrift Compiler`)},
+	{[]string{".h", ".hpp", ".cc", ".cpp"}, 1, regexp.MustCompile(`^// Generated by the gRPC`)},
+	{[]string{".h"}, 1, regexp.MustCompile(`^/\* DO NOT EDIT THIS FILE - it is machine generated \*/`)},
+	{[]string{".c", ".cpp"}, 1, regexp.MustCompile(`Generated by Cython`)},
+	{[]string{".js"}, 1, regexp.MustCompile(`^// Generated by CoffeeScript`)},
+	{[]string{".js"}, 1, regexp.MustCompile(`Generated by PEG\.js`)},
+	{[]string{".js"}, 1, regexp.MustCompile(`^/\* (parser generated by jison|generated by jison-lex) `)},
+	{[]string{".java"}, 1, regexp.MustCompile(`^/\* The following code was generated by JFlex `)},
+	{[]string{".java"}, 1, regexp.MustCompile(`^// This is a generated file\. Not intended for manual editing\.`)},
+	{[]string{".rb"}, 3, regexp.MustCompile(`^# This file is automatically generated by Racc`)},
+	{[]string{".Rd"}, 1, regexp.MustCompile(`% Generated by roxygen2: do not edit by hand`)},
+}
+
+// minifiedLineLength is the average length of the lines of minified
+// scripts and style sheets.
+const minifiedLineLength = 110
+
+// IsGenerated reports whether the file at the path was written by a tool
+// instead of by hand, guessing from its name and the comments in its first
+// lines with the rules of GitHub Linguist.
+func IsGenerated(filename string, content []byte) bool {
+	base := path.Base(filename)
+	if generatedNames[base] {
+		return true
+	}
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return true
+		}
+	}
+	for _, dir := range generatedDirs {
+		if strings.HasPrefix(filename, dir) || strings.Contains(filename, "/"+dir) {
+			return true
+		}
+	}
+
+	ext := path.Ext(base)
+	if (ext == ".js" || ext == ".css") && isMinified(content) {
+		return true
+	}
+
+	lines := bytes.SplitN(content, []byte("\n"), 41)
+	for _, rule := range generatedRules {
+		if !hasExtension(rule.extensions, ext) {
+			continue
+		}
+		for i := 0; i < rule.lines && i < len(lines); i++ {
+			if rule.marker.Match(bytes.TrimSuffix(lines[i], []byte("\r"))) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasExtension(extensions []string, ext string) bool {
+	if extensions == nil {
+		return true
+	}
+	for _, e := range extensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// isMinified reports whether the lines of the content are too long to have
+// been written by hand.
+func isMinified(content []byte) bool {
+	if len(content) == 0 {
+		return false
+	}
+	lines := bytes.Count(content, []byte("\n"))
+	if !bytes.HasSuffix(content, []byte("\n")) {
+		lines++
+	}
+	return len(content)/lines > minifiedLineLength
+}
@@ -0,0 +1,42 @@
+package udf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsGenerated(t *testing.T) {
+	cases := []struct {
+		filename, content string
+		generated         bool
+	}{
+		{"gen.go", "// Code generated by stringer. DO NOT EDIT.\n\npackage a\n", true},
+		{"gen.go", "// Copyright 2018\n\n// Code generated by tool. DO NOT EDIT.\npackage a\n", true},
+		{"gen.go", "// Code generated by stringer. DO NOT EDIT\npackage a\n", false},
+		{"main.go", "package main\n\n// Generated by hand, DO NOT EDIT.\n", false},
+		{"main.go", "// Code generated code is tested elsewhere, DO NOT EDIT. these lines\n", false},
+		{"build.py", "# Generated by the build script\n", false},
+		{"api.rs", "// @generated\n", false},
+		{"a_pb2.py", "# Generated by the protocol buffer compiler.  DO NOT EDIT!\n", true},
+		{"a.pb.h", "// Generated by the protocol buffer compiler.  DO NOT EDIT!\n", true},
+		{"a.go", "// Generated by the protocol buffer compiler.  DO NOT EDIT!\n", false},
+		{"a.grpc.pb.cc", "// Generated by the gRPC C++ plugin.\n", true},
+		{"a.thrift.py", "#\n# Autogenerated by Thrift Compiler (0.9.3)\n", true},
+		{"a.c", "/* Generated by Cython 0.29 */\n", true},
+		{"a.js", "// Generated by CoffeeScript 1.12.7\n(function() {})\n", true},
+		{"a.min.js", strings.Repeat("var a=1;", 50) + "\n", true},
+		{"a.js", "var a = 1;\nvar b = 2;\n", false},
+		{"a.js.map", "{}", true},
+		{"Form.Designer.cs", "class Form {}\n", false},
+		{"Form.designer.cs", "class Form {}\n", true},
+		{"node_modules/left-pad/index.js", "module.exports = 1\n", true},
+		{"yarn.lock", "", true},
+		{"lexer.rb", "#\n# DO NOT MODIFY!!!!\n# This file is automatically generated by Racc 1.4.14\n", true},
+	}
+
+	for _, c := range cases {
+		if got := IsGenerated(c.filename, []byte(c.content)); got != c.generated {
+			t.Errorf("IsGenerated(%q, %q) = %v, expected %v", c.filename, c.content, got, c.generated)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package udf
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/chrislusf/gleam/gio"
+	"github.com/pkg/errors"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+// GrepOption configures Grep.
+type GrepOption func(*grepConfig)
+
+type grepConfig struct {
+	context    int
+	maxMatches int
+}
+
+// WithContextLines sets the number of lines before and after the matches
+// emitted with them, 2 by default.
+func WithContextLines(n int) GrepOption {
+	return func(c *grepConfig) { c.context = n }
+}
+
+// WithMaxMatches sets the maximum number of matches emitted per file, 100
+// by default, or no maximum if it is 0.
+func WithMaxMatches(n int) GrepOption {
+	return func(c *grepConfig) { c.maxMatches = n }
+}
+
+// Grep emits a row per match of the regular expression in the lines of
+// the content, with the number of the line and the column where the match
+// starts, both starting at 1, the line, and the lines before and after it,
+// see WithContextLines, appended. Rows of binary content emit nothing.
+func Grep(pattern string, contentIdx int, opts ...GrepOption) gio.Mapper {
+	config := &grepConfig{context: 2, maxMatches: 100}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	re, compileErr := regexp.Compile(pattern)
+	return func(x []interface{}) error {
+		if compileErr != nil {
+			return errors.Wrapf(compileErr, "invalid grep pattern %s", pattern)
+		}
+
+		content := gio.ToBytes(x[contentIdx])
+		if len(content) == 0 || enry.IsBinary(content) {
+			return nil
+		}
+
+		lines := bytes.Split(content, []byte("\n"))
+		matches := 0
+		for i, line := range lines {
+			for _, loc := range re.FindAllIndex(line, -1) {
+				if config.maxMatches > 0 && matches == config.maxMatches {
+					return nil
+				}
+				matches++
+
+				start, end := i-config.context, i+1+config.context
+				if start < 0 {
+					start = 0
+				}
+				if end > len(lines) {
+					end = len(lines)
+				}
+				before, after := lines[start:i], lines[i+1:end]
+				err := gio.Emit(append(x[:len(x):len(x)],
+					int64(i+1),
+					int64(loc[0]+1),
+					string(line),
+					string(bytes.Join(before, []byte("\n"))),
+					string(bytes.Join(after, []byte("\n"))),
+				)...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,211 @@
+package udf
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chrislusf/gleam/gio"
+)
+
+// ExtractTokens emits a row per token of the identifiers of the content,
+// see Identifiers and SplitIdentifier, with the token and the number of
+// times it is found appended, in the order they are first found. The
+// language is the one classified by ClassifyLanguage. Since the rest of
+// the row is repeated for every token, select only the columns needed,
+// leaving out the content.
+func ExtractTokens(langIdx, contentIdx int) gio.Mapper {
+	return func(x []interface{}) error {
+		var (
+			tokens []string
+			counts = make(map[string]int64)
+		)
+		for _, id := range Identifiers(gio.ToString(x[langIdx]), gio.ToBytes(x[contentIdx])) {
+			for _, t := range SplitIdentifier(id) {
+				if counts[t] == 0 {
+					tokens = append(tokens, t)
+				}
+				counts[t]++
+			}
+		}
+
+		for _, t := range tokens {
+			if err := gio.Emit(append(x[:len(x):len(x)], t, counts[t])...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// stringQuotes are the quotes of the string literals of the languages
+// which are not single and double quotes, like the raw strings of Go or
+// the characters of Rust, which are not closed like 'a.
+var stringQuotes = map[string]string{
+	"Go":          "\"'`",
+	"JavaScript":  "\"'`",
+	"JSX":         "\"'`",
+	"TypeScript":  "\"'`",
+	"Rust":        `"`,
+	"Haskell":     `"`,
+	"OCaml":       `"`,
+	"F#":          `"`,
+	"Clojure":     `"`,
+	"Common Lisp": `"`,
+	"Emacs Lisp":  `"`,
+	"Scheme":      `"`,
+	"Vim script":  `'`,
+}
+
+// keywords of the languages, which are left out of the identifiers.
+var keywords = map[string]map[string]bool{
+	"C":          wordSet(cKeywords),
+	"C++":        wordSet(cKeywords + " bool catch class const_cast delete dynamic_cast explicit false friend inline mutable namespace new nullptr operator private protected public reinterpret_cast static_cast template this throw true try typeid typename using virtual"),
+	"C#":         wordSet("abstract as base bool break byte case catch char checked class const continue decimal default delegate do double else enum event explicit extern false finally fixed float for foreach goto if implicit in int interface internal is lock long namespace new null object operator out override params private protected public readonly ref return sbyte sealed short sizeof stackalloc static string struct switch this throw true try typeof uint ulong unchecked unsafe ushort using var virtual void volatile while async await"),
+	"Go":         wordSet("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota"),
+	"Java":       wordSet("abstract assert boolean break byte case catch char class const continue default do double else enum extends final finally float for goto if implements import instanceof int interface long native new null package private protected public return short static strictfp super switch synchronized this throw throws transient true false try void volatile while var"),
+	"JavaScript": wordSet(jsKeywords),
+	"JSX":        wordSet(jsKeywords),
+	"TypeScript": wordSet(jsKeywords + " any boolean declare enum implements interface keyof namespace never number private protected public readonly string type unknown"),
+	"PHP":        wordSet("abstract and array as break callable case catch class clone const continue declare default do echo else elseif empty enddeclare endfor endforeach endif endswitch endwhile extends final finally fn for foreach function global goto if implements include include_once instanceof insteadof interface isset list namespace new or print private protected public require require_once return static switch throw trait try unset use var while xor yield null true false self parent this"),
+	"Python":     wordSet("False None True and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield self"),
+	"Ruby":       wordSet("BEGIN END alias and begin break case class def defined do else elsif end ensure false for if in module next nil not or redo rescue retry return self super then true undef unless until when while yield"),
+	"Rust":       wordSet("as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while"),
+	"Shell":      wordSet("case do done elif else esac fi for function if in select then until while echo export local return"),
+}
+
+const (
+	cKeywords  = "auto break case char const continue default do double else enum extern float for goto if int long register return short signed sizeof static struct switch typedef union unsigned void volatile while NULL"
+	jsKeywords = "async await break case catch class const continue debugger default delete do else export extends false finally for function if import in instanceof let new null of return super switch this throw true try typeof undefined var void while with yield"
+)
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// Identifiers returns the identifiers of the source code, in the order
+// they are found, leaving out the keywords, comments, string literals and
+// numbers of the language, as far as they are known.
+func Identifiers(lang string, content []byte) []string {
+	syntax := commentSyntaxes[lang]
+	quotes, ok := stringQuotes[lang]
+	if !ok {
+		quotes = `"'`
+	}
+	reserved := keywords[lang]
+
+	var ids []string
+	for i := 0; i < len(content); {
+		rest := content[i:]
+		if start, end, ok := blockStart(syntax, rest); ok {
+			i += skipPast(rest[len(start):], []byte(end)) + len(start)
+			continue
+		}
+		if lineComment(syntax, rest) {
+			i += skipPast(rest, []byte("\n"))
+			continue
+		}
+
+		r, size := utf8.DecodeRune(rest)
+		switch {
+		case r < utf8.RuneSelf && strings.IndexByte(quotes, byte(r)) >= 0:
+			i += skipString(rest)
+		case unicode.IsDigit(r):
+			i += wordLength(rest)
+		case isIdentifierStart(r):
+			n := wordLength(rest)
+			if id := string(rest[:n]); !reserved[id] {
+				ids = append(ids, id)
+			}
+			i += n
+		default:
+			i += size
+		}
+	}
+	return ids
+}
+
+// skipPast returns the length of b up to the end of the first occurrence
+// of the marker, or all of it if it is not found.
+func skipPast(b, marker []byte) int {
+	if i := bytes.Index(b, marker); i >= 0 {
+		return i + len(marker)
+	}
+	return len(b)
+}
+
+// skipString returns the length of the string literal b starts with. Only
+// strings quoted by backquotes span several lines, the rest end at the end
+// of the line, so unclosed quotes, like in "don't", do not hide the code
+// that follows.
+func skipString(b []byte) int {
+	quote := b[0]
+	for i := 1; i < len(b); i++ {
+		switch {
+		case b[i] == '\\' && quote != '`':
+			i++
+		case b[i] == quote:
+			return i + 1
+		case b[i] == '\n' && quote != '`':
+			return i
+		}
+	}
+	return len(b)
+}
+
+func isIdentifierStart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
+
+// wordLength returns the length of the identifier or number b starts with.
+func wordLength(b []byte) int {
+	n := 0
+	for n < len(b) {
+		r, size := utf8.DecodeRune(b[n:])
+		if !isIdentifierStart(r) && !unicode.IsDigit(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// SplitIdentifier splits the identifier by its case and underscores, like
+// parseHTTPResponse or parse_http_response into parse, http and response,
+// lower cased. Tokens of one character are left out.
+func SplitIdentifier(id string) []string {
+	var (
+		tokens []string
+		runes  = []rune(id)
+		start  = 0
+	)
+	flush := func(end int) {
+		if end-start > 1 {
+			tokens = append(tokens, strings.ToLower(string(runes[start:end])))
+		}
+		start = end
+	}
+
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '$':
+			flush(i)
+			start = i + 1
+		case i > start && unicode.IsUpper(r):
+			prev := runes[i-1]
+			// a new word after a lower case letter, like parseHTTP, or
+			// the last upper case letter of an acronym, like HTTPResponse
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				flush(i)
+			}
+		}
+	}
+	flush(len(runes))
+	return tokens
+}
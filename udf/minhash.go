@@ -0,0 +1,20 @@
+package udf
+
+import (
+	"github.com/chrislusf/gleam/gio"
+	"github.com/eiso/go-engine/minhash"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+// MinHash appends to the rows the MinHash signature of the content,
+// encoded by minhash.Signature.Bytes, to find similar files. Binary
+// content and content with too few tokens have a nil signature.
+func MinHash(contentIdx int) gio.Mapper {
+	return func(x []interface{}) error {
+		content := gio.ToBytes(x[contentIdx])
+		if enry.IsBinary(content) {
+			return gio.Emit(append(x, nil)...)
+		}
+		return gio.Emit(append(x, minhash.New(content).Bytes())...)
+	}
+}
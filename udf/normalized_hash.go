@@ -0,0 +1,119 @@
+package udf
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"unicode/utf8"
+
+	"github.com/chrislusf/gleam/gio"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+// HashOption configures NormalizedHash.
+type HashOption func(*hashConfig)
+
+type hashConfig struct {
+	dropComments bool
+}
+
+// WithoutComments leaves the comments out of the content hashed by
+// NormalizedHash, as far as the comment syntax of the language is known.
+func WithoutComments() HashOption {
+	return func(c *hashConfig) { c.dropComments = true }
+}
+
+// NormalizedHash appends to the rows the hash of their content, see
+// HashContent. The language is the one classified by ClassifyLanguage,
+// used to leave out comments, see WithoutComments.
+func NormalizedHash(langIdx, contentIdx int, opts ...HashOption) gio.Mapper {
+	return func(x []interface{}) error {
+		hash := HashContent(gio.ToString(x[langIdx]), gio.ToBytes(x[contentIdx]), opts...)
+		return gio.Emit(append(x, hash)...)
+	}
+}
+
+// HashContent returns the SHA-1 of the content normalized, see Normalize,
+// in hexadecimal, so files which only differ in white space at the end of
+// the lines or line endings have the same hash. The comments of the
+// language are left out with WithoutComments. Binary content is hashed as
+// it is.
+func HashContent(lang string, content []byte, opts ...HashOption) string {
+	config := &hashConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if !enry.IsBinary(content) {
+		if config.dropComments {
+			content = StripComments(lang, content)
+		}
+		content = Normalize(content)
+	}
+
+	sum := sha1.Sum(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Normalize turns CRLF and CR line endings into LF, removes the white space
+// at the end of the lines and the blank lines at the end of the content.
+func Normalize(content []byte) []byte {
+	content = bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1)
+	content = bytes.Replace(content, []byte("\r"), []byte("\n"), -1)
+
+	lines := bytes.Split(content, []byte("\n"))
+	for i, line := range lines {
+		lines[i] = bytes.TrimRight(line, " \t\f\v")
+	}
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// StripComments removes the comments of the source code, by the comment
+// syntax of the language, and the blank lines, so files which only differ
+// in comments are the same. Markers of comments inside string literals are
+// not taken as comments. Content of other languages is left as it is.
+func StripComments(lang string, content []byte) []byte {
+	syntax, ok := commentSyntaxes[lang]
+	if !ok {
+		return content
+	}
+	quotes, ok := stringQuotes[lang]
+	if !ok {
+		quotes = `"'`
+	}
+
+	var out bytes.Buffer
+	for i := 0; i < len(content); {
+		rest := content[i:]
+		if start, end, ok := blockStart(syntax, rest); ok {
+			i += skipPast(rest[len(start):], []byte(end)) + len(start)
+			continue
+		}
+		if lineComment(syntax, rest) {
+			if n := bytes.IndexByte(rest, '\n'); n >= 0 {
+				i += n
+			} else {
+				i = len(content)
+			}
+			continue
+		}
+
+		r, size := utf8.DecodeRune(rest)
+		if r < utf8.RuneSelf && bytes.IndexByte([]byte(quotes), byte(r)) >= 0 {
+			size = skipString(rest)
+		}
+		out.Write(rest[:size])
+		i += size
+	}
+
+	var lines [][]byte
+	for _, line := range bytes.Split(out.Bytes(), []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
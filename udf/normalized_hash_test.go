@@ -0,0 +1,31 @@
+package udf
+
+import "testing"
+
+func TestHashContent(t *testing.T) {
+	code := "# helpers\ndef f():\n    return 1\n"
+	cases := []struct {
+		lang, a, b   string
+		dropComments bool
+		same         bool
+	}{
+		{"Python", code, "# helpers\r\ndef f():  \r\n    return 1\r\n\r\n", false, true},
+		{"Python", code, "# other helpers\ndef f():\n    return 1\n", false, false},
+		{"Python", code, "# other helpers\ndef f():\n    return 1\n", true, true},
+		{"Python", code, "def f():\n    return 2\n", true, false},
+		// binary content is hashed as it is
+		{"", "\x00\x01 \n", "\x00\x01\n", false, false},
+		{"", "\x00\x01\n", "\x00\x01\n", true, true},
+	}
+
+	for _, c := range cases {
+		var opts []HashOption
+		if c.dropComments {
+			opts = append(opts, WithoutComments())
+		}
+		a, b := HashContent(c.lang, []byte(c.a), opts...), HashContent(c.lang, []byte(c.b), opts...)
+		if (a == b) != c.same {
+			t.Errorf("expected the hashes of %q and %q to be the same: %v, got %s and %s", c.a, c.b, c.same, a, b)
+		}
+	}
+}
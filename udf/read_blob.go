@@ -1,27 +1,38 @@
 package udf
 
 import (
+	"container/list"
+	"io"
 	"io/ioutil"
+	"log"
+	"sync"
 
 	"github.com/chrislusf/gleam/gio"
 	"github.com/pkg/errors"
 
+	engine "github.com/eiso/go-engine"
 	gogit "gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 )
 
-// ReadBlob TODO
-// Needs to be refactored to accept the blob itself, not the hash
+// RepositoryCacheSize is the number of repositories kept open by every
+// mapper process reading blobs.
+var RepositoryCacheSize = 16
+
+// ReadBlob appends the content of the blob with the hash in the column
+// blobHashIdx, read from the repository in the column repoPathIdx, to the
+// rows. The repository can have any of the layouts the sources read, and
+// the last ones used are kept open, see RepositoryCacheSize.
 func ReadBlob(repoPathIdx, blobHashIdx int) gio.Mapper {
 	return func(x []interface{}) error {
 		repoPath := gio.ToString(x[repoPathIdx])
 		blobHash := plumbing.NewHash(gio.ToString(x[blobHashIdx]))
 
 		if blobHash.IsZero() {
-			return gio.Emit(x[:len(x)+1]...)
+			return gio.Emit(append(x, nil)...)
 		}
 
-		r, err := gogit.PlainOpen(repoPath)
+		r, err := repositories.get(repoPath)
 		if err != nil {
 			return errors.Wrapf(err, "could not open repo at %s", repoPath)
 		}
@@ -45,3 +56,56 @@ func ReadBlob(repoPathIdx, blobHashIdx int) gio.Mapper {
 		return gio.Emit(append(x, contents)...)
 	}
 }
+
+// Repository returns the repository at path from the repositories kept
+// open by the mapper process, see RepositoryCacheSize, opening it if it is
+// not one of them. It must not be closed.
+func Repository(path string) (*gogit.Repository, error) {
+	return repositories.get(path)
+}
+
+var repositories = &repositoryCache{
+	elements: make(map[string]*list.Element),
+	order:    list.New(),
+}
+
+// repositoryCache keeps the last repositories opened, since consecutive
+// rows usually come from the same repository.
+type repositoryCache struct {
+	mu       sync.Mutex
+	elements map[string]*list.Element
+	// order holds the cached repositories, the last used first.
+	order *list.List
+}
+
+type cachedRepository struct {
+	path   string
+	repo   *gogit.Repository
+	closer io.Closer
+}
+
+func (c *repositoryCache) get(path string) (*gogit.Repository, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.elements[path]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*cachedRepository).repo, nil
+	}
+
+	repo, closer, err := engine.OpenRepository(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.elements[path] = c.order.PushFront(&cachedRepository{path: path, repo: repo, closer: closer})
+	for c.order.Len() > RepositoryCacheSize && c.order.Len() > 1 {
+		last := c.order.Remove(c.order.Back()).(*cachedRepository)
+		delete(c.elements, last.path)
+		// siva files stay open until their repository is closed
+		if err := last.closer.Close(); err != nil {
+			log.Printf("could not close repository %s: %s", last.path, err)
+		}
+	}
+	return repo, nil
+}
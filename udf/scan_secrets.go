@@ -0,0 +1,31 @@
+package udf
+
+import (
+	"github.com/chrislusf/gleam/gio"
+	"github.com/eiso/go-engine/secrets"
+	enry "gopkg.in/src-d/enry.v1"
+)
+
+// ScanSecrets scans the content of the rows for secrets with the rules,
+// secrets.DefaultRules if there are none, emitting a row per secret found
+// with the ID of the rule, the number of the line and the secret redacted
+// appended. Rows of binary or missing content emit nothing.
+func ScanSecrets(contentIdx int, rules ...secrets.Rule) gio.Mapper {
+	if len(rules) == 0 {
+		rules = secrets.DefaultRules
+	}
+
+	return func(x []interface{}) error {
+		content := gio.ToBytes(x[contentIdx])
+		if len(content) == 0 || enry.IsBinary(content) {
+			return nil
+		}
+
+		for _, f := range secrets.Scan(content, rules) {
+			if err := gio.Emit(append(x[:len(x):len(x)], f.RuleID, int64(f.Line), f.Match)...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
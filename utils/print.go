@@ -8,7 +8,9 @@ import (
 
 // PrintRow prints a row with tab seperated columns
 func PrintRow(row *util.Row) error {
-	fmt.Printf("%v\t", row.K[0])
+	for _, k := range row.K {
+		fmt.Printf("%v\t", k)
+	}
 	for _, v := range row.V {
 		fmt.Printf("%v\t", v)
 	}